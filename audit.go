@@ -0,0 +1,30 @@
+package tracker
+
+import (
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+// Administrative action on the tracker.
+type AuditLog struct {
+	ID     uuid.UUID `db:"id" json:"id"`
+	Actor  string    `db:"actor" json:"actor"`
+	Action string    `db:"action" json:"action"`
+	Target string    `db:"target" json:"target"`
+	// Value of the target before and after the action, nil if it did not exist.
+	OldValue  any            `db:"old_value" json:"old_value"`
+	NewValue  any            `db:"new_value" json:"new_value"`
+	Details   map[string]any `db:"details" json:"details"`
+	CreatedAt time.Time      `db:"created_at" json:"created_at"`
+}
+
+// Filter for searching the audit log. Zero fields are not filtered on.
+type AuditLogFilter struct {
+	Actor  string
+	Action string
+	Target string
+	From   time.Time
+	To     time.Time
+	Limit  int
+}
@@ -0,0 +1,85 @@
+package tracker
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SVG line chart of seeders and leechers over time.
+type swarmChart struct {
+	Width    int
+	Height   int
+	Max      int
+	From     time.Time
+	To       time.Time
+	Seeders  string
+	Leechers string
+}
+
+// Plots history between from and to. Points are placed by time so gaps stay visible.
+func newSwarmChart(history []SwarmSnapshot, from time.Time, to time.Time, width int, height int) swarmChart {
+	chart := swarmChart{Width: width, Height: height, Max: 1, From: from, To: to}
+	for _, h := range history {
+		chart.Max = max(chart.Max, h.Seeders, h.Leechers)
+	}
+
+	var seeders, leechers []string
+	span := to.Sub(from).Seconds()
+	for _, h := range history {
+		x := float64(width) * h.At.Sub(from).Seconds() / span
+		y := func(v int) float64 {
+			return float64(height) - float64(height*v)/float64(chart.Max)
+		}
+		seeders = append(seeders, fmt.Sprintf("%.1f,%.1f", x, y(h.Seeders)))
+		leechers = append(leechers, fmt.Sprintf("%.1f,%.1f", x, y(h.Leechers)))
+	}
+	chart.Seeders = strings.Join(seeders, " ")
+	chart.Leechers = strings.Join(leechers, " ")
+
+	return chart
+}
+
+// SVG bar chart of completions per day.
+type completedChart struct {
+	Width  int
+	Height int
+	Max    int
+	Bars   []completedBar
+}
+
+type completedBar struct {
+	X, Y, Width, Height float64
+	Day                 string
+	Completed           int
+}
+
+// Plots one bar per UTC day of the days before and including to.
+// Days without activity have an empty bar.
+func newCompletedChart(activity []SwarmActivity, to time.Time, days int, width int, height int) completedChart {
+	chart := completedChart{Width: width, Height: height, Max: 1}
+
+	completed := map[string]int{}
+	for _, a := range activity {
+		day := a.At.UTC().Format(time.DateOnly)
+		completed[day] += a.Completed
+		chart.Max = max(chart.Max, completed[day])
+	}
+
+	w := float64(width) / float64(days)
+	first := to.UTC().Truncate(24*time.Hour).AddDate(0, 0, 1-days)
+	for i := 0; i < days; i++ {
+		day := first.AddDate(0, 0, i).Format(time.DateOnly)
+		h := float64(height*completed[day]) / float64(chart.Max)
+		chart.Bars = append(chart.Bars, completedBar{
+			X:         float64(i) * w,
+			Y:         float64(height) - h,
+			Width:     w * 0.8,
+			Height:    h,
+			Day:       day,
+			Completed: completed[day],
+		})
+	}
+
+	return chart
+}
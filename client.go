@@ -0,0 +1,80 @@
+package tracker
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Names of clients by their Azureus-style peer_id prefix, e.g. -qB4620-.
+var azureusClients = map[string]string{
+	"AZ": "Vuze",
+	"BC": "BitComet",
+	"BI": "BiglyBT",
+	"BT": "BitTorrent",
+	"DE": "Deluge",
+	"FD": "Free Download Manager",
+	"KT": "KTorrent",
+	"LT": "libtorrent",
+	"lt": "rTorrent",
+	"PI": "PicoTorrent",
+	"qB": "qBittorrent",
+	"TR": "Transmission",
+	"TX": "Tixati",
+	"UM": "µTorrent Mac",
+	"UT": "µTorrent",
+	"UW": "µTorrent Web",
+	"WD": "WebTorrent Desktop",
+	"WW": "WebTorrent",
+}
+
+// Tries to return the client name and version based on peer_id.
+// The name is "unknown" and the version empty for unrecognized peer_ids.
+func ParseClient(peerID []byte) (string, string) {
+	id := string(peerID)
+
+	// -XXVVVV-
+	if len(id) >= 8 && id[0] == '-' && id[7] == '-' {
+		name, ok := azureusClients[id[1:3]]
+		if !ok {
+			return "unknown", ""
+		}
+		v := id[3:7]
+		if id[1:3] == "TR" {
+			// Transmission 3.00 is 3000 and 0.80 beta is 080B
+			return name, v[:1] + "." + v[1:3]
+		}
+		var parts []string
+		for _, c := range v {
+			switch {
+			case c >= '0' && c <= '9':
+				parts = append(parts, string(c))
+			case c >= 'A' && c <= 'Z':
+				parts = append(parts, strconv.Itoa(int(c-'A')+10))
+			case c >= 'a' && c <= 'z':
+				parts = append(parts, strconv.Itoa(int(c-'a')+36))
+			default:
+				return name, ""
+			}
+		}
+		// drop trailing zero parts, keeping major.minor
+		for len(parts) > 2 && parts[len(parts)-1] == "0" {
+			parts = parts[:len(parts)-1]
+		}
+		return name, strings.Join(parts, ".")
+	}
+
+	// Mainline M7-4-3-- or M10-0-0-
+	if strings.HasPrefix(id, "M") {
+		fields := strings.Split(strings.TrimRight(id[1:min(len(id), 8)], "-"), "-")
+		if len(fields) == 3 {
+			for _, f := range fields {
+				if f == "" || strings.Trim(f, "0123456789") != "" {
+					return "unknown", ""
+				}
+			}
+			return "BitTorrent", strings.Join(fields, ".")
+		}
+	}
+
+	return "unknown", ""
+}
@@ -0,0 +1,32 @@
+package tracker
+
+import "testing"
+
+func TestParseClient(t *testing.T) {
+	tests := []struct {
+		peerID  string
+		name    string
+		version string
+	}{
+		{"-TR3000-dybw6lsnsc17", "Transmission", "3.00"},
+		{"-TR410Z-dybw6lsnsc17", "Transmission", "4.10"},
+		{"-qB4620-abcdefghijkl", "qBittorrent", "4.6.2"},
+		{"-LT2090-abcdefghijkl", "libtorrent", "2.0.9"},
+		{"-lt0D80-abcdefghijkl", "rTorrent", "0.13.8"},
+		{"-UT3550-abcdefghijkl", "µTorrent", "3.5.5"},
+		{"-DE13F0-abcdefghijkl", "Deluge", "1.3.15"},
+		{"-BI3600-abcdefghijkl", "BiglyBT", "3.6"},
+		{"M7-4-3--abcdefghijkl", "BitTorrent", "7.4.3"},
+		{"M10-0-0-abcdefghijkl", "BitTorrent", "10.0.0"},
+		{"-ZZ1000-abcdefghijkl", "unknown", ""},
+		{"Mozilla-abcdefghijkl", "unknown", ""},
+		{"", "unknown", ""},
+	}
+
+	for _, tt := range tests {
+		name, version := ParseClient([]byte(tt.peerID))
+		if name != tt.name || version != tt.version {
+			t.Errorf("%q: want %s %s, got %s %s", tt.peerID, tt.name, tt.version, name, version)
+		}
+	}
+}
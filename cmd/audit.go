@@ -0,0 +1,28 @@
+package main
+
+import (
+	"context"
+	osuser "os/user"
+
+	"github.com/rs/zerolog/log"
+	"github.com/salimnassim/tracker"
+)
+
+// Records an action of a subcommand. The actor is the system user running it.
+func auditCLI(ctx context.Context, server *tracker.Server, action string, target string, oldValue any, newValue any) {
+	actor := "cli"
+	if u, err := osuser.Current(); err == nil {
+		actor += ":" + u.Username
+	}
+
+	err := server.Store().AddAuditLog(ctx, tracker.AuditLog{
+		Actor:    actor,
+		Action:   action,
+		Target:   target,
+		OldValue: oldValue,
+		NewValue: newValue,
+	})
+	if err != nil {
+		log.Error().Err(err).Msgf("cant add audit log for %s", action)
+	}
+}
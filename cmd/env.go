@@ -0,0 +1,48 @@
+package main
+
+import (
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Returns environment variable key as an int or def if it is not set.
+func envInt(key string, def int) int {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	i, err := strconv.Atoi(v)
+	if err != nil {
+		log.Fatal().Err(err).Msgf("%s is not a valid integer", key)
+	}
+	return i
+}
+
+// Returns environment variable key as a float or def if it is not set.
+func envFloat(key string, def float64) float64 {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		log.Fatal().Err(err).Msgf("%s is not a valid number", key)
+	}
+	return f
+}
+
+// Returns environment variable key as a duration or def if it is not set.
+func envDuration(key string, def time.Duration) time.Duration {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		log.Fatal().Err(err).Msgf("%s is not a valid duration", key)
+	}
+	return d
+}
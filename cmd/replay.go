@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+	"github.com/salimnassim/tracker"
+)
+
+// Replays a capture written with RECORD_PATH.
+//
+//	tracker replay -file capture.jsonl -target http://localhost:9999 -speed 2
+//	tracker replay -file capture.jsonl -direct
+func replay(args []string) {
+	flags := flag.NewFlagSet("replay", flag.ExitOnError)
+	file := flags.String("file", "", "path to the JSONL capture")
+	target := flags.String("target", "http://localhost:9999", "base URL of a running tracker")
+	direct := flags.Bool("direct", false, "replay against an in-process server using the environment config")
+	speed := flags.Float64("speed", 1, "speed factor, 0 replays as fast as possible")
+	concurrency := flags.Int("concurrency", 64, "maximum number of requests in flight")
+	flags.Parse(args)
+
+	if *file == "" {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	capture, err := os.Open(*file)
+	if err != nil {
+		log.Fatal().Err(err).Msg("cant open capture")
+	}
+	defer capture.Close()
+
+	replayer := &tracker.Replayer{
+		Target:      *target,
+		Speed:       *speed,
+		Concurrency: *concurrency,
+		Diff:        os.Stdout,
+	}
+
+	if *direct {
+		server := tracker.NewServer(newConfig())
+		defer server.Close(context.Background())
+
+		r := mux.NewRouter()
+		tracker.HandleTracker(r, server)
+		replayer.Handler = r
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	report, err := replayer.Replay(ctx, capture)
+	fmt.Println(report)
+	if err != nil {
+		log.Fatal().Err(err).Msg("replay failed")
+	}
+}
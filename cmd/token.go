@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/rs/zerolog/log"
+	"github.com/salimnassim/tracker"
+)
+
+// Manages API tokens.
+//
+//	tracker token create -name ci -scope read
+//	tracker token revoke -id 7f1c...
+//	tracker token list
+func token(args []string) {
+	if len(args) < 1 {
+		fmt.Fprintln(os.Stderr, "usage: tracker token create|revoke|list [flags]")
+		os.Exit(2)
+	}
+
+	ctx := context.Background()
+	flags := flag.NewFlagSet("token "+args[0], flag.ExitOnError)
+
+	switch args[0] {
+	case "create":
+		name := flags.String("name", "", "name of the token")
+		scope := flags.String("scope", "read", "scope of the token: read, moderate or admin")
+		flags.Parse(args[1:])
+
+		if *name == "" {
+			flags.Usage()
+			os.Exit(2)
+		}
+		s, err := tracker.ParseScope(*scope)
+		if err != nil {
+			log.Fatal().Err(err).Msg("cant parse scope")
+		}
+
+		secret, hash, err := tracker.NewTokenSecret()
+		if err != nil {
+			log.Fatal().Err(err).Msg("cant generate token")
+		}
+
+		server := tracker.NewServer(newConfig())
+		defer server.Close(ctx)
+
+		t, err := server.Store().AddToken(ctx, *name, s, hash)
+		if err != nil {
+			log.Fatal().Err(err).Msg("cant add token")
+		}
+
+		auditCLI(ctx, server, "token.create", t.ID.String(), nil, map[string]any{"name": t.Name, "scope": t.Scope})
+
+		fmt.Printf("created token %s (%s) with scope %s\n", t.ID, t.Name, t.Scope)
+		fmt.Printf("%s\n", secret)
+		fmt.Fprintln(os.Stderr, "the token is shown only once")
+	case "revoke":
+		id := flags.String("id", "", "id of the token")
+		flags.Parse(args[1:])
+
+		tokenID, err := uuid.FromString(*id)
+		if err != nil {
+			flags.Usage()
+			os.Exit(2)
+		}
+
+		server := tracker.NewServer(newConfig())
+		defer server.Close(ctx)
+
+		err = server.Store().RevokeToken(ctx, tokenID)
+		if err != nil {
+			log.Fatal().Err(err).Msg("cant revoke token")
+		}
+		auditCLI(ctx, server, "token.revoke", tokenID.String(), nil, nil)
+
+		fmt.Printf("revoked token %s\n", tokenID)
+	case "list":
+		flags.Parse(args[1:])
+
+		server := tracker.NewServer(newConfig())
+		defer server.Close(ctx)
+
+		tokens, err := server.Store().Tokens(ctx)
+		if err != nil {
+			log.Fatal().Err(err).Msg("cant list tokens")
+		}
+
+		formatTime := func(t *time.Time) string {
+			if t == nil {
+				return "-"
+			}
+			return t.Format(time.RFC3339)
+		}
+
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "ID\tNAME\tSCOPE\tCREATED\tLAST USED\tREVOKED")
+		for _, t := range tokens {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n",
+				t.ID, t.Name, t.Scope, t.CreatedAt.Format(time.RFC3339), formatTime(t.LastUsedAt), formatTime(t.RevokedAt))
+		}
+		tw.Flush()
+	default:
+		fmt.Fprintln(os.Stderr, "usage: tracker token create|revoke|list [flags]")
+		os.Exit(2)
+	}
+}
@@ -2,9 +2,13 @@ package main
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"os"
+	"os/signal"
 	"path"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/gorilla/mux"
@@ -15,16 +19,93 @@ import (
 )
 
 func main() {
-	ctx := context.Background()
 	zerolog.TimeFieldFormat = zerolog.TimeFormatUnix
+	// handlers log with the request logger, anything else falls back to the global one
+	zerolog.DefaultContextLogger = &log.Logger
 
-	// create config
+	level := zerolog.InfoLevel
+	if v := os.Getenv("LOG_LEVEL"); v != "" {
+		var err error
+		level, err = zerolog.ParseLevel(v)
+		if err != nil {
+			log.Fatal().Err(err).Msg("LOG_LEVEL is not a valid level")
+		}
+	}
+	zerolog.SetGlobalLevel(level)
+
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "replay":
+			replay(os.Args[2:])
+			return
+		case "token":
+			token(os.Args[2:])
+			return
+		case "user":
+			user(os.Args[2:])
+			return
+		}
+	}
+
+	serve()
+}
+
+// Creates config from environment variables.
+func newConfig() *tracker.ServerConfig {
 	config := tracker.NewServerConfig(
 		os.Getenv("ADDRESS"),
 		os.Getenv("ANNOUNCE_URL"),
 		os.Getenv("DSN"),
 		os.Getenv("TEMPLATE_PATH"),
 	)
+	config.AdminUsername = os.Getenv("ADMIN_USERNAME")
+	config.AdminPassword = os.Getenv("ADMIN_PASSWORD")
+	config.APIMaskIPs = os.Getenv("API_MASK_IPS") == "true"
+	config.LogSampleRate = envFloat("LOG_SAMPLE_RATE", 1)
+	config.Private = os.Getenv("PRIVATE") == "true"
+	if v := os.Getenv("ANNOUNCE_LIST"); v != "" {
+		config.AnnounceList = strings.Split(v, ",")
+	}
+	config.PublicURL = os.Getenv("PUBLIC_URL")
+	config.RequireLogin = os.Getenv("REQUIRE_LOGIN") == "true"
+	config.SessionTTL = envDuration("SESSION_TTL", 7*24*time.Hour)
+	config.SessionCookieSecure = os.Getenv("SESSION_COOKIE_SECURE") != "false"
+	config.OIDC.Issuer = os.Getenv("OIDC_ISSUER")
+	config.OIDC.ClientID = os.Getenv("OIDC_CLIENT_ID")
+	config.OIDC.ClientSecret = os.Getenv("OIDC_CLIENT_SECRET")
+	config.OIDC.RedirectURL = os.Getenv("OIDC_REDIRECT_URL")
+	if v := os.Getenv("OIDC_GROUPS_CLAIM"); v != "" {
+		config.OIDC.GroupsClaim = v
+	}
+	groupScopes, err := tracker.ParseGroupScopes(os.Getenv("OIDC_GROUPS"))
+	if err != nil {
+		log.Fatal().Err(err).Msg("cant parse OIDC_GROUPS")
+	}
+	config.OIDC.GroupScopes = groupScopes
+	config.WriteBehind = tracker.WriteBehindConfig{
+		FlushInterval: envDuration("WRITE_BEHIND_INTERVAL", 0),
+		FlushSize:     envInt("WRITE_BEHIND_FLUSH_SIZE", 1000),
+		QueueSize:     envInt("WRITE_BEHIND_QUEUE_SIZE", 10000),
+	}
+	config.HealthTimeout = envDuration("HEALTH_TIMEOUT", 2*time.Second)
+	return config
+}
+
+func serve() {
+	ctx := context.Background()
+
+	// create config
+	config := newConfig()
+
+	// export traces if a collector is configured
+	stopTracing := func(context.Context) error { return nil }
+	if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" || os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != "" {
+		var err error
+		stopTracing, err = tracker.StartTracing(ctx)
+		if err != nil {
+			log.Fatal().Err(err).Msg("cant start tracing")
+		}
+	}
 
 	// create server
 	server := tracker.NewServer(config)
@@ -38,21 +119,81 @@ func main() {
 	fs := http.FileServer(http.Dir(path.Join(os.Getenv("STATIC_PATH"))))
 	r.PathPrefix("/static/").Handler(http.StripPrefix("/static/", fs))
 
+	r.Use(tracker.TracingMiddleware, tracker.AccessLogMiddleware, tracker.MetricsMiddleware)
 	r.Handle("/metrics", promhttp.Handler())
 	r.Handle("/health", tracker.HealthHandler())
+	r.Handle("/health/live", tracker.HealthHandler())
+	r.Handle("/health/ready", tracker.ReadinessHandler(server))
+
+	r.Handle("/login", tracker.LoginHandler(server)).Methods(http.MethodGet, http.MethodPost)
+	r.Handle("/logout", tracker.LogoutHandler(server)).Methods(http.MethodPost)
+	r.Handle("/login/oidc", tracker.OIDCLoginHandler(server)).Methods(http.MethodGet)
+	r.Handle("/login/oidc/callback", tracker.OIDCCallbackHandler(server)).Methods(http.MethodGet)
+
+	// Subrouter for pages that can require a login.
+	ur := r.NewRoute().Subrouter()
+	ur.Handle("/", tracker.IndexHandler(server))
+	ur.Handle("/torrent/{id}", tracker.TorrentHandler(server))
+	ur.Handle("/torrent/{id}/download", tracker.DownloadHandler(server))
+	ur.Handle("/stats", tracker.StatsHandler(server))
+	ur.Use(tracker.LoginRequiredMiddleware(server))
+
+	// Feeds and their downloads authenticate with the passkey in the path when a login is needed.
+	r.Handle("/torrent/{id}/download/{passkey}", tracker.DownloadHandler(server)).Methods(http.MethodGet)
+	r.Handle("/feed/rss", tracker.FeedHandler(server, "rss")).Methods(http.MethodGet)
+	r.Handle("/feed/atom", tracker.FeedHandler(server, "atom")).Methods(http.MethodGet)
+	r.Handle("/feed/rss/{passkey}", tracker.FeedHandler(server, "rss")).Methods(http.MethodGet)
+	r.Handle("/feed/atom/{passkey}", tracker.FeedHandler(server, "atom")).Methods(http.MethodGet)
 
-	r.Handle("/", tracker.IndexHandler(server))
-	r.Handle("/torrent/{id}", tracker.TorrentHandler(server))
+	r.Handle("/api/openapi.json", tracker.OpenAPIHandler())
+
+	// Subrouter for the JSON API, which needs credentials if login is required.
+	api := r.PathPrefix("/api/v1").Subrouter()
+	api.Use(tracker.APILoginRequiredMiddleware(server))
+	api.Handle("/torrents", tracker.APITorrentsHandler(server)).Methods(http.MethodGet)
+	api.Handle("/torrents/{id}", tracker.APITorrentHandler(server)).Methods(http.MethodGet)
+	api.Handle("/torrents/{id}/peers", tracker.APIPeersHandler(server)).Methods(http.MethodGet)
+	api.Handle("/stats", tracker.APIStatsHandler(server)).Methods(http.MethodGet)
+
+	// Subrouter for routes that require read scope.
+	rr := r.NewRoute().Subrouter()
+	rr.Handle("/log", tracker.AnnounceLogHandler(server))
+	rr.Use(tracker.AuthMiddleware(server, tracker.ScopeRead))
+
+	// Subrouter for routes that require moderate scope.
+	ar := r.NewRoute().Subrouter()
+	ar.Handle("/api/admin/torrents", tracker.AdminUploadTorrentHandler(server)).Methods(http.MethodPost)
+	ar.Handle("/api/admin/torrents/{id}", tracker.AdminDeleteTorrentHandler(server)).Methods(http.MethodDelete)
+	ar.Handle("/api/admin/torrents/{id}", tracker.AdminTorrentLabelsHandler(server)).Methods(http.MethodPatch)
+	ar.Handle("/api/admin/torrents/{id}/flags", tracker.AdminTorrentFlagsHandler(server)).Methods(http.MethodPut)
+	ar.Handle("/api/admin/torrents/{id}/freeze", tracker.AdminFreezeTorrentHandler(server, true)).Methods(http.MethodPost)
+	ar.Handle("/api/admin/torrents/{id}/unfreeze", tracker.AdminFreezeTorrentHandler(server, false)).Methods(http.MethodPost)
+	ar.Handle("/api/admin/torrents/{id}/reset-completed", tracker.AdminResetCompletedHandler(server)).Methods(http.MethodPost)
+	ar.Handle("/api/admin/torrents/{id}/peers/{peer}", tracker.AdminEvictPeerHandler(server)).Methods(http.MethodDelete)
+	ar.Handle("/api/admin/peers", tracker.AdminEvictIPHandler(server)).Methods(http.MethodDelete)
+	ar.Handle("/api/admin/audit", tracker.AdminAuditLogHandler(server)).Methods(http.MethodGet)
+	ar.Handle("/audit", tracker.AuditLogHandler(server)).Methods(http.MethodGet)
+	ar.Handle("/upload", tracker.UploadHandler(server)).Methods(http.MethodGet, http.MethodPost)
+	ar.Use(tracker.AuthMiddleware(server, tracker.ScopeModerate))
 
 	// Subrouter for plaintext.
 	sr := r.NewRoute().Subrouter()
-	r.Handle("/announce", tracker.AnnounceHandler(server))
-	r.Handle("/scrape", tracker.ScrapeHandler(server))
+	tracker.HandleTracker(sr, server)
 	sr.Use(tracker.PlaintextMiddleware)
 
+	// record tracker requests for replay
+	if os.Getenv("RECORD_PATH") != "" {
+		record, err := os.OpenFile(os.Getenv("RECORD_PATH"), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			log.Fatal().Err(err).Msg("cant open record file")
+		}
+		defer record.Close()
+		sr.Use(tracker.RecorderMiddleware(record))
+	}
+
 	log.Info().Str("source", "tracker_http").Msgf("starting tracker (address: %s, announce url: %s)", config.Address, config.AnnounceURL)
 
-	http := &http.Server{
+	httpServer := &http.Server{
 		ReadHeaderTimeout: 5 * time.Second,
 		ReadTimeout:       10 * time.Second,
 		WriteTimeout:      5 * time.Second,
@@ -61,16 +202,135 @@ func main() {
 	}
 
 	// remove stale peers every 5 minutes
-	server.RunTask(5*time.Minute, func(ts tracker.TorrentStorable) {
+	server.RunTask("clean_peers", 5*time.Minute, func(ts tracker.TorrentStorable) error {
 		_, err := ts.CleanPeers(ctx, 1*time.Hour)
 		if err != nil {
 			log.Error().Err(err).Msg("cant clean peers in task")
-			return
+			return err
+		}
+		return nil
+	})
+
+	// remove expired sessions every hour
+	server.RunTask("clean_sessions", 1*time.Hour, func(ts tracker.TorrentStorable) error {
+		_, err := ts.CleanSessions(ctx)
+		if err != nil {
+			log.Error().Err(err).Msg("cant clean sessions in task")
+			return err
+		}
+		return nil
+	})
+
+	// keep announce log partitions ahead of time and drop expired ones
+	logRetention := envDuration("LOG_RETENTION", 0)
+	maintainLog := func(ts tracker.TorrentStorable) error {
+		_, err := ts.CreateLogPartitions(ctx, time.Now(), 3)
+		if err != nil {
+			log.Error().Err(err).Msg("cant create announce log partitions in task")
+			return err
+		}
+		if logRetention > 0 {
+			n, err := ts.DropLogPartitions(ctx, time.Now().Add(-logRetention))
+			if err != nil {
+				log.Error().Err(err).Msg("cant drop announce log partitions in task")
+				return err
+			}
+			if n > 0 {
+				log.Info().Msgf("dropped %d announce log partitions", n)
+			}
+		}
+		return nil
+	}
+	maintainLog(server.Store())
+	server.RunTask("announce_log_partitions", 1*time.Hour, maintainLog)
+
+	// fix drifted seeder and leecher counters
+	server.RunTask("reconcile_torrents", envDuration("RECONCILE_INTERVAL", 1*time.Hour), func(ts tracker.TorrentStorable) error {
+		n, err := ts.ReconcileTorrents(ctx)
+		if err != nil {
+			log.Error().Err(err).Msg("cant reconcile torrents in task")
+			return err
 		}
+		if n > 0 {
+			log.Warn().Msgf("reconciled counters of %d torrents", n)
+		}
+		return nil
 	})
 
-	err := http.ListenAndServe()
+	// compute stats in the background so requests only read the cached ones
+	refreshStats := func(ts tracker.TorrentStorable) error {
+		_, err := server.RefreshStats(ctx)
+		if err != nil {
+			log.Error().Err(err).Msg("cant refresh stats in task")
+			return err
+		}
+		return nil
+	}
+	refreshStats(server.Store())
+	server.RunTask("stats", envDuration("STATS_INTERVAL", 1*time.Minute), refreshStats)
+
+	// snapshot swarms, roll them up into hours and days and drop expired ones
+	if interval := envDuration("SNAPSHOT_INTERVAL", 5*time.Minute); interval > 0 {
+		retention := map[string]time.Duration{
+			tracker.SnapshotRaw:  envDuration("SNAPSHOT_RAW_RETENTION", 48*time.Hour),
+			tracker.SnapshotHour: envDuration("SNAPSHOT_HOUR_RETENTION", 90*24*time.Hour),
+			tracker.SnapshotDay:  envDuration("SNAPSHOT_DAY_RETENTION", 0),
+		}
+		server.RunTask("swarm_snapshots", interval, func(ts tracker.TorrentStorable) error {
+			now := time.Now().UTC().Truncate(time.Second)
+			_, err := ts.SnapshotSwarms(ctx, now)
+			if err != nil {
+				log.Error().Err(err).Msg("cant snapshot swarms in task")
+				return err
+			}
+			for _, resolution := range []string{tracker.SnapshotHour, tracker.SnapshotDay} {
+				_, err := ts.RollupSnapshots(ctx, resolution, now)
+				if err != nil {
+					log.Error().Err(err).Msgf("cant roll up %s snapshots in task", resolution)
+					return err
+				}
+			}
+			for resolution, d := range retention {
+				if d <= 0 {
+					continue
+				}
+				_, err := ts.DropSnapshots(ctx, resolution, now.Add(-d))
+				if err != nil {
+					log.Error().Err(err).Msgf("cant drop %s snapshots in task", resolution)
+					return err
+				}
+			}
+			return nil
+		})
+	}
+
+	go func() {
+		err := httpServer.ListenAndServe()
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal().Err(err).Str("source", "tracker_http").Msg("tracker exited")
+		}
+	}()
+
+	// wait for a signal and shut down, flushing pending writes
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	<-signals
+
+	log.Info().Str("source", "tracker_http").Msg("shutting down tracker")
+
+	shutdownCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	err := httpServer.Shutdown(shutdownCtx)
+	if err != nil {
+		log.Error().Err(err).Str("source", "tracker_http").Msg("cant shut down http server")
+	}
+	err = server.Close(shutdownCtx)
+	if err != nil {
+		log.Error().Err(err).Str("source", "tracker_http").Msg("cant close server")
+	}
+	err = stopTracing(shutdownCtx)
 	if err != nil {
-		log.Fatal().Err(err).Str("source", "tracker_http").Msg("tracker exited")
+		log.Error().Err(err).Str("source", "tracker_http").Msg("cant flush traces")
 	}
 }
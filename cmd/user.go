@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/salimnassim/tracker"
+)
+
+// Reads a password from the first line of stdin.
+func readPassword() string {
+	fmt.Fprint(os.Stderr, "password: ")
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		log.Fatal().Err(err).Msg("cant read password")
+	}
+	password := strings.TrimRight(line, "\r\n")
+	if len(password) < 8 {
+		log.Fatal().Msg("password has to be at least 8 characters")
+	}
+	return password
+}
+
+// Manages web UI users. Passwords are read from stdin.
+//
+//	tracker user add -username alice -scope moderate
+//	tracker user passwd -username alice
+//	tracker user passkey -username alice
+//	tracker user delete -username alice
+func user(args []string) {
+	if len(args) < 1 {
+		fmt.Fprintln(os.Stderr, "usage: tracker user add|passwd|passkey|delete [flags]")
+		os.Exit(2)
+	}
+
+	ctx := context.Background()
+	flags := flag.NewFlagSet("user "+args[0], flag.ExitOnError)
+	username := flags.String("username", "", "username of the user")
+
+	switch args[0] {
+	case "add":
+		scope := flags.String("scope", "read", "scope of the user: read, moderate or admin")
+		flags.Parse(args[1:])
+
+		if *username == "" {
+			flags.Usage()
+			os.Exit(2)
+		}
+		s, err := tracker.ParseScope(*scope)
+		if err != nil {
+			log.Fatal().Err(err).Msg("cant parse scope")
+		}
+
+		hash, err := tracker.HashPassword(readPassword())
+		if err != nil {
+			log.Fatal().Err(err).Msg("cant hash password")
+		}
+
+		server := tracker.NewServer(newConfig())
+		defer server.Close(ctx)
+
+		u, err := server.Store().AddUser(ctx, *username, hash, s)
+		if err != nil {
+			log.Fatal().Err(err).Msg("cant add user")
+		}
+		auditCLI(ctx, server, "user.add", u.Username, nil, map[string]any{"scope": u.Scope})
+
+		fmt.Printf("added user %s with scope %s\n", u.Username, u.Scope)
+	case "passwd":
+		flags.Parse(args[1:])
+
+		if *username == "" {
+			flags.Usage()
+			os.Exit(2)
+		}
+
+		hash, err := tracker.HashPassword(readPassword())
+		if err != nil {
+			log.Fatal().Err(err).Msg("cant hash password")
+		}
+
+		server := tracker.NewServer(newConfig())
+		defer server.Close(ctx)
+
+		err = server.Store().UpdateUserPassword(ctx, *username, hash)
+		if err != nil {
+			log.Fatal().Err(err).Msg("cant update password")
+		}
+		auditCLI(ctx, server, "user.passwd", *username, nil, nil)
+
+		fmt.Printf("changed password of %s\n", *username)
+	case "passkey":
+		flags.Parse(args[1:])
+
+		if *username == "" {
+			flags.Usage()
+			os.Exit(2)
+		}
+
+		server := tracker.NewServer(newConfig())
+		defer server.Close(ctx)
+
+		passkey, err := server.Store().ResetPasskey(ctx, *username)
+		if err != nil {
+			log.Fatal().Err(err).Msg("cant reset passkey")
+		}
+		auditCLI(ctx, server, "user.passkey", *username, nil, nil)
+
+		fmt.Printf("reset passkey of %s, downloaded .torrent files have to be downloaded again\n", *username)
+		fmt.Printf("%s\n", passkey)
+	case "delete":
+		flags.Parse(args[1:])
+
+		if *username == "" {
+			flags.Usage()
+			os.Exit(2)
+		}
+
+		server := tracker.NewServer(newConfig())
+		defer server.Close(ctx)
+
+		err := server.Store().DeleteUser(ctx, *username)
+		if err != nil {
+			log.Fatal().Err(err).Msg("cant delete user")
+		}
+		auditCLI(ctx, server, "user.delete", *username, nil, nil)
+
+		fmt.Printf("deleted user %s\n", *username)
+	default:
+		fmt.Fprintln(os.Stderr, "usage: tracker user add|passwd|passkey|delete [flags]")
+		os.Exit(2)
+	}
+}
@@ -1,10 +1,45 @@
 package tracker
 
+import "time"
+
 type ServerConfig struct {
 	Address      string
 	AnnounceURL  string
 	DSN          string
 	TemplatePath string
+
+	// Credentials for the authenticated routes.
+	AdminUsername string
+	AdminPassword string
+
+	// Mask peer IPs in API responses.
+	APIMaskIPs bool
+
+	// Fraction of announces without an event that are written to the announce log.
+	// Announces with an event are always logged.
+	LogSampleRate float64
+
+	// Only accept announces with the passkey of a user for uploaded torrents.
+	Private bool
+	// Additional trackers added as tiers to the announce-list of downloaded .torrent files.
+	AnnounceList []string
+	// Public URL of the web UI used for absolute links in feeds.
+	// Defaults to the scheme and host of the announce URL.
+	PublicURL string
+
+	// Require a login for the index and torrent pages.
+	RequireLogin bool
+	// Lifetime of web UI sessions.
+	SessionTTL time.Duration
+	// Send session cookies only over HTTPS.
+	SessionCookieSecure bool
+	// Single sign-on with an OpenID Connect provider, enabled if the issuer is set.
+	OIDC OIDCConfig
+
+	WriteBehind WriteBehindConfig
+
+	// How long readiness checks wait for the store.
+	HealthTimeout time.Duration
 }
 
 func NewServerConfig(address string, announceURL string, dsn string, templatePath string) *ServerConfig {
@@ -13,5 +48,13 @@ func NewServerConfig(address string, announceURL string, dsn string, templatePat
 		AnnounceURL:  announceURL,
 		DSN:          dsn,
 		TemplatePath: templatePath,
+
+		LogSampleRate:       1,
+		SessionTTL:          7 * 24 * time.Hour,
+		SessionCookieSecure: true,
+		HealthTimeout:       2 * time.Second,
+		OIDC: OIDCConfig{
+			GroupsClaim: "groups",
+		},
 	}
 }
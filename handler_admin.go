@@ -0,0 +1,296 @@
+package tracker
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/gofrs/uuid"
+	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+)
+
+type AdminFlagsRequest struct {
+	Flags []string `json:"flags"`
+}
+
+// Fields that are not set are not changed.
+type AdminLabelsRequest struct {
+	Name     *string   `json:"name"`
+	Category *string   `json:"category"`
+	Tags     *[]string `json:"tags"`
+}
+
+type AdminEvictResponse struct {
+	Evicted int `json:"evicted"`
+}
+
+// Records an admin action by the authenticated user of r with the value
+// of target before and after it. The action has already happened, so
+// failures are only logged.
+func (sv *Server) audit(r *http.Request, action string, target string, oldValue any, newValue any, details map[string]any) {
+	principal, _ := PrincipalFromContext(r.Context())
+	err := sv.store.AddAuditLog(r.Context(), AuditLog{
+		Actor:    principal.Name,
+		Action:   action,
+		Target:   target,
+		OldValue: oldValue,
+		NewValue: newValue,
+		Details:  details,
+	})
+	if err != nil {
+		log.Ctx(r.Context()).Error().Err(err).Str("source", "http_admin").Msgf("cant add audit log for %s", action)
+	}
+}
+
+// Gets torrent from route vars and replies with an error if it can't.
+func adminTorrent(server *Server, w http.ResponseWriter, r *http.Request) (Torrent, bool) {
+	torrent, err := torrentFromVars(server, r, true)
+	if errors.Is(err, pgx.ErrNoRows) {
+		replyJSONError(w, "torrent not found", http.StatusNotFound)
+		return Torrent{}, false
+	}
+	if err != nil {
+		log.Ctx(r.Context()).Error().Err(err).Str("source", "http_admin").Msg("cant get torrent")
+		replyJSONError(w, "internal server error", http.StatusInternalServerError)
+		return Torrent{}, false
+	}
+	return torrent, true
+}
+
+func AdminDeleteTorrentHandler(server *Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		torrent, ok := adminTorrent(server, w, r)
+		if !ok {
+			return
+		}
+
+		err := server.store.DeleteTorrent(r.Context(), torrent.ID)
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			log.Ctx(r.Context()).Error().Err(err).Str("source", "http_admin").Msg("cant delete torrent")
+			replyJSONError(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		server.audit(r, "torrent.delete", torrent.ID.String(), &torrent, nil, map[string]any{
+			"info_hash": hex.EncodeToString(torrent.InfoHash),
+		})
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// Sets and unsets torrent flags and replies with the updated torrent.
+func updateTorrentFlags(server *Server, w http.ResponseWriter, r *http.Request, action string, torrent Torrent, set []string, unset []string) {
+	updated, err := server.store.UpdateTorrentFlags(r.Context(), torrent.ID, set, unset)
+	if errors.Is(err, pgx.ErrNoRows) {
+		replyJSONError(w, "torrent not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Ctx(r.Context()).Error().Err(err).Str("source", "http_admin").Msg("cant update torrent flags")
+		replyJSONError(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	server.audit(r, action, torrent.ID.String(),
+		map[string]any{"flags": torrent.Flags},
+		map[string]any{"flags": updated.Flags},
+		nil)
+	replyJSON(w, &updated, http.StatusOK)
+}
+
+// Replaces all flags of a torrent with the flags in the request body.
+func AdminTorrentFlagsHandler(server *Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		torrent, ok := adminTorrent(server, w, r)
+		if !ok {
+			return
+		}
+
+		var req AdminFlagsRequest
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			replyJSONError(w, "body is not valid", http.StatusBadRequest)
+			return
+		}
+
+		var unset []string
+		for _, flag := range req.Flags {
+			if !slices.Contains(torrentFlags, flag) {
+				replyJSONError(w, "flag is not valid", http.StatusBadRequest)
+				return
+			}
+		}
+		for _, flag := range torrentFlags {
+			if !slices.Contains(req.Flags, flag) {
+				unset = append(unset, flag)
+			}
+		}
+
+		updateTorrentFlags(server, w, r, "torrent.flags", torrent, req.Flags, unset)
+	}
+}
+
+// Sets name, category and tags of a torrent.
+func AdminTorrentLabelsHandler(server *Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		torrent, ok := adminTorrent(server, w, r)
+		if !ok {
+			return
+		}
+
+		var req AdminLabelsRequest
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			replyJSONError(w, "body is not valid", http.StatusBadRequest)
+			return
+		}
+
+		name, category, tags := torrent.Name, torrent.Category, torrent.Tags
+		if req.Name != nil {
+			name = strings.TrimSpace(*req.Name)
+			if len(name) > 255 {
+				replyJSONError(w, "name is too long", http.StatusBadRequest)
+				return
+			}
+		}
+		if req.Category != nil {
+			category = *req.Category
+			if category != "" && !ValidLabel(category) {
+				replyJSONError(w, "category is not valid", http.StatusBadRequest)
+				return
+			}
+		}
+		if req.Tags != nil {
+			tags = ParseTags(strings.Join(*req.Tags, ","))
+			for _, tag := range tags {
+				if !ValidLabel(tag) {
+					replyJSONError(w, "tag is not valid", http.StatusBadRequest)
+					return
+				}
+			}
+		}
+
+		updated, err := server.store.UpdateTorrentLabels(r.Context(), torrent.ID, name, category, tags)
+		if errors.Is(err, pgx.ErrNoRows) {
+			replyJSONError(w, "torrent not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Ctx(r.Context()).Error().Err(err).Str("source", "http_admin").Msg("cant update torrent labels")
+			replyJSONError(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		server.audit(r, "torrent.labels", torrent.ID.String(),
+			map[string]any{"name": torrent.Name, "category": torrent.Category, "tags": torrent.Tags},
+			map[string]any{"name": updated.Name, "category": updated.Category, "tags": updated.Tags},
+			nil)
+		replyJSON(w, &updated, http.StatusOK)
+	}
+}
+
+// Sets or unsets the frozen flag of a torrent.
+func AdminFreezeTorrentHandler(server *Server, freeze bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		torrent, ok := adminTorrent(server, w, r)
+		if !ok {
+			return
+		}
+
+		if freeze {
+			updateTorrentFlags(server, w, r, "torrent.freeze", torrent, []string{TorrentFrozen}, nil)
+			return
+		}
+		updateTorrentFlags(server, w, r, "torrent.unfreeze", torrent, nil, []string{TorrentFrozen})
+	}
+}
+
+func AdminResetCompletedHandler(server *Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		torrent, ok := adminTorrent(server, w, r)
+		if !ok {
+			return
+		}
+
+		err := server.store.ResetCompleted(r.Context(), torrent.ID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			replyJSONError(w, "torrent not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Ctx(r.Context()).Error().Err(err).Str("source", "http_admin").Msg("cant reset completed")
+			replyJSONError(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		server.audit(r, "torrent.reset_completed", torrent.ID.String(),
+			map[string]any{"completed": torrent.Completed},
+			map[string]any{"completed": 0},
+			nil)
+		torrent.Completed = 0
+		replyJSON(w, &torrent, http.StatusOK)
+	}
+}
+
+func AdminEvictPeerHandler(server *Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		torrent, ok := adminTorrent(server, w, r)
+		if !ok {
+			return
+		}
+
+		peerID, err := uuid.FromString(mux.Vars(r)["peer"])
+		if err != nil {
+			replyJSONError(w, "peer not found", http.StatusNotFound)
+			return
+		}
+
+		peer, err := server.store.EvictPeer(r.Context(), torrent.ID, peerID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			replyJSONError(w, "peer not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Ctx(r.Context()).Error().Err(err).Str("source", "http_admin").Msg("cant evict peer")
+			replyJSONError(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		server.audit(r, "peer.evict", peerID.String(), newAPIPeer(peer, false), nil, map[string]any{
+			"torrent_id": torrent.ID.String(),
+		})
+		replyJSON(w, AdminEvictResponse{Evicted: 1}, http.StatusOK)
+	}
+}
+
+func AdminEvictIPHandler(server *Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ip := r.URL.Query().Get("ip")
+		if net.ParseIP(ip) == nil {
+			replyJSONError(w, "ip is not valid", http.StatusBadRequest)
+			return
+		}
+
+		peers, err := server.store.EvictPeersByIP(r.Context(), ip)
+		if err != nil {
+			log.Ctx(r.Context()).Error().Err(err).Str("source", "http_admin").Msg("cant evict peers by ip")
+			replyJSONError(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		evicted := make([]APIPeer, 0, len(peers))
+		for _, p := range peers {
+			evicted = append(evicted, newAPIPeer(p, false))
+		}
+		server.audit(r, "peer.evict_ip", ip, evicted, nil, map[string]any{
+			"evicted": len(peers),
+		})
+		replyJSON(w, AdminEvictResponse{Evicted: len(peers)}, http.StatusOK)
+	}
+}
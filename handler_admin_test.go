@@ -0,0 +1,340 @@
+package tracker
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/gofrs/uuid"
+	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v5"
+)
+
+// Keeps torrents and peers in memory for the admin handlers and records the audit log.
+type adminStore struct {
+	TorrentStorable
+	torrents map[uuid.UUID]Torrent
+	peers    []Peer
+	audit    []AuditLog
+}
+
+func (s *adminStore) Torrent(ctx context.Context, infoHash []byte) (Torrent, error) {
+	for _, t := range s.torrents {
+		if string(t.InfoHash) == string(infoHash) {
+			return t, nil
+		}
+	}
+	return Torrent{}, pgx.ErrNoRows
+}
+
+func (s *adminStore) TorrentByID(ctx context.Context, torrentID uuid.UUID) (Torrent, error) {
+	t, ok := s.torrents[torrentID]
+	if !ok {
+		return Torrent{}, pgx.ErrNoRows
+	}
+	return t, nil
+}
+
+func (s *adminStore) DeleteTorrent(ctx context.Context, torrentID uuid.UUID) error {
+	if _, ok := s.torrents[torrentID]; !ok {
+		return pgx.ErrNoRows
+	}
+	delete(s.torrents, torrentID)
+	return nil
+}
+
+func (s *adminStore) UpdateTorrentFlags(ctx context.Context, torrentID uuid.UUID, set []string, unset []string) (Torrent, error) {
+	t, ok := s.torrents[torrentID]
+	if !ok {
+		return Torrent{}, pgx.ErrNoRows
+	}
+	flags := slices.DeleteFunc(slices.Clone(t.Flags), func(f string) bool { return slices.Contains(unset, f) })
+	for _, f := range set {
+		if !slices.Contains(flags, f) {
+			flags = append(flags, f)
+		}
+	}
+	t.Flags = flags
+	s.torrents[torrentID] = t
+	return t, nil
+}
+
+func (s *adminStore) UpdateTorrentLabels(ctx context.Context, torrentID uuid.UUID, name string, category string, tags []string) (Torrent, error) {
+	t, ok := s.torrents[torrentID]
+	if !ok {
+		return Torrent{}, pgx.ErrNoRows
+	}
+	t.Name, t.Category, t.Tags = name, category, tags
+	s.torrents[torrentID] = t
+	return t, nil
+}
+
+func (s *adminStore) ResetCompleted(ctx context.Context, torrentID uuid.UUID) error {
+	t, ok := s.torrents[torrentID]
+	if !ok {
+		return pgx.ErrNoRows
+	}
+	t.Completed = 0
+	s.torrents[torrentID] = t
+	return nil
+}
+
+func (s *adminStore) EvictPeer(ctx context.Context, torrentID uuid.UUID, peerID uuid.UUID) (Peer, error) {
+	for i, p := range s.peers {
+		if p.TorrentID == torrentID && p.ID == peerID {
+			s.peers = slices.Delete(s.peers, i, i+1)
+			return p, nil
+		}
+	}
+	return Peer{}, pgx.ErrNoRows
+}
+
+func (s *adminStore) EvictPeersByIP(ctx context.Context, ip string) ([]Peer, error) {
+	var evicted []Peer
+	s.peers = slices.DeleteFunc(s.peers, func(p Peer) bool {
+		if p.IP.Equal(net.ParseIP(ip)) {
+			evicted = append(evicted, p)
+			return true
+		}
+		return false
+	})
+	return evicted, nil
+}
+
+func (s *adminStore) AddAuditLog(ctx context.Context, entry AuditLog) error {
+	s.audit = append(s.audit, entry)
+	return nil
+}
+
+// Routes the admin API like cmd does, authenticated as a moderator.
+func newAdminRouter(server *Server) *mux.Router {
+	r := mux.NewRouter()
+	r.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), principalKey{}, Principal{Name: "mod", Scope: ScopeModerate})
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	})
+	r.Handle("/api/admin/torrents/{id}", AdminDeleteTorrentHandler(server)).Methods(http.MethodDelete)
+	r.Handle("/api/admin/torrents/{id}", AdminTorrentLabelsHandler(server)).Methods(http.MethodPatch)
+	r.Handle("/api/admin/torrents/{id}/flags", AdminTorrentFlagsHandler(server)).Methods(http.MethodPut)
+	r.Handle("/api/admin/torrents/{id}/freeze", AdminFreezeTorrentHandler(server, true)).Methods(http.MethodPost)
+	r.Handle("/api/admin/torrents/{id}/unfreeze", AdminFreezeTorrentHandler(server, false)).Methods(http.MethodPost)
+	r.Handle("/api/admin/torrents/{id}/reset-completed", AdminResetCompletedHandler(server)).Methods(http.MethodPost)
+	r.Handle("/api/admin/torrents/{id}/peers/{peer}", AdminEvictPeerHandler(server)).Methods(http.MethodDelete)
+	r.Handle("/api/admin/peers", AdminEvictIPHandler(server)).Methods(http.MethodDelete)
+	return r
+}
+
+func newAdminStore() (*adminStore, Torrent) {
+	torrent := Torrent{
+		ID:        uuid.Must(uuid.NewV4()),
+		InfoHash:  []byte("aaaaaaaaaaaaaaaaaaaa"),
+		Name:      "ubuntu.iso",
+		Completed: 7,
+		Flags:     []string{TorrentHidden},
+	}
+	store := &adminStore{torrents: map[uuid.UUID]Torrent{torrent.ID: torrent}}
+	for i := 0; i < 3; i++ {
+		store.peers = append(store.peers, Peer{
+			ID:        uuid.Must(uuid.NewV4()),
+			TorrentID: torrent.ID,
+			PeerID:    []byte("-TR3000-dybw6lsnsc17"),
+			IP:        net.IPv4(10, 0, 0, byte(1+i%2)),
+			Port:      6881,
+		})
+	}
+	return store, torrent
+}
+
+func TestAdminTorrentHandlers(t *testing.T) {
+	store, torrent := newAdminStore()
+	server := &Server{config: &ServerConfig{}, store: store}
+	r := newAdminRouter(server)
+
+	do := func(method string, target string, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return w
+	}
+	lastAudit := func() AuditLog {
+		t.Helper()
+		if len(store.audit) == 0 {
+			t.Fatal("want audit log entry")
+		}
+		return store.audit[len(store.audit)-1]
+	}
+	base := "/api/admin/torrents/" + torrent.ID.String()
+
+	// hidden torrents are found by moderators, by id and info hash
+	w := do(http.MethodPost, base+"/freeze", "")
+	if w.Code != http.StatusOK || !slices.Contains(store.torrents[torrent.ID].Flags, TorrentFrozen) {
+		t.Fatalf("freeze: want 200 and frozen, got %d %s", w.Code, w.Body)
+	}
+	if a := lastAudit(); a.Action != "torrent.freeze" || a.Actor != "mod" || a.Target != torrent.ID.String() {
+		t.Errorf("freeze: unexpected audit %+v", a)
+	}
+	w = do(http.MethodPost, "/api/admin/torrents/6161616161616161616161616161616161616161/unfreeze", "")
+	if w.Code != http.StatusOK || slices.Contains(store.torrents[torrent.ID].Flags, TorrentFrozen) {
+		t.Fatalf("unfreeze: want 200 and not frozen, got %d %s", w.Code, w.Body)
+	}
+
+	w = do(http.MethodPut, base+"/flags", `{"flags":["frozen"]}`)
+	if w.Code != http.StatusOK || !slices.Equal(store.torrents[torrent.ID].Flags, []string{TorrentFrozen}) {
+		t.Errorf("flags: want only frozen, got %d %v", w.Code, store.torrents[torrent.ID].Flags)
+	}
+	if a := lastAudit(); a.Action != "torrent.flags" || a.OldValue == nil || a.NewValue == nil {
+		t.Errorf("flags: unexpected audit %+v", a)
+	}
+	for _, body := range []string{`{"flags":["shiny"]}`, `flags`} {
+		if w := do(http.MethodPut, base+"/flags", body); w.Code != http.StatusBadRequest {
+			t.Errorf("flags %s: want 400, got %d", body, w.Code)
+		}
+	}
+
+	w = do(http.MethodPost, base+"/reset-completed", "")
+	var reset Torrent
+	json.Unmarshal(w.Body.Bytes(), &reset)
+	if w.Code != http.StatusOK || store.torrents[torrent.ID].Completed != 0 || reset.Completed != 0 {
+		t.Errorf("reset: want completed 0, got %d %s", w.Code, w.Body)
+	}
+	if a := lastAudit(); a.Action != "torrent.reset_completed" {
+		t.Errorf("reset: unexpected audit %+v", a)
+	}
+
+	peerID := store.peers[0].ID.String()
+	w = do(http.MethodDelete, base+"/peers/"+peerID, "")
+	if w.Code != http.StatusOK || len(store.peers) != 2 {
+		t.Errorf("evict: want one peer evicted, got %d, %d left", w.Code, len(store.peers))
+	}
+	if a := lastAudit(); a.Action != "peer.evict" || a.OldValue.(APIPeer).ID != peerID || a.NewValue != nil {
+		t.Errorf("evict: want the evicted peer as old value, got %+v", a)
+	}
+	for _, peer := range []string{uuid.Must(uuid.NewV4()).String(), "nope"} {
+		if w := do(http.MethodDelete, base+"/peers/"+peer, ""); w.Code != http.StatusNotFound {
+			t.Errorf("evict %s: want 404, got %d", peer, w.Code)
+		}
+	}
+
+	w = do(http.MethodDelete, "/api/admin/peers?ip=10.0.0.1", "")
+	var evicted AdminEvictResponse
+	json.Unmarshal(w.Body.Bytes(), &evicted)
+	if w.Code != http.StatusOK || evicted.Evicted != 1 || len(store.peers) != 1 {
+		t.Errorf("evict ip: want one peer evicted, got %d %s", w.Code, w.Body)
+	}
+	if a := lastAudit(); a.Action != "peer.evict_ip" || len(a.OldValue.([]APIPeer)) != 1 || a.OldValue.([]APIPeer)[0].IP != "10.0.0.1" {
+		t.Errorf("evict ip: want the evicted peers as old value, got %+v", a)
+	}
+	if w := do(http.MethodDelete, "/api/admin/peers?ip=10.0.0", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("evict ip: want 400, got %d", w.Code)
+	}
+
+	w = do(http.MethodDelete, base, "")
+	if w.Code != http.StatusNoContent || len(store.torrents) != 0 {
+		t.Errorf("delete: want 204 and no torrents, got %d", w.Code)
+	}
+	if a := lastAudit(); a.Action != "torrent.delete" || a.OldValue == nil || a.NewValue != nil {
+		t.Errorf("delete: unexpected audit %+v", a)
+	}
+
+	audited := len(store.audit)
+	for _, target := range []string{base, base + "/freeze", base + "/reset-completed", "/api/admin/torrents/nope/freeze"} {
+		method := http.MethodPost
+		if target == base {
+			method = http.MethodDelete
+		}
+		if w := do(method, target, ""); w.Code != http.StatusNotFound {
+			t.Errorf("%s %s: want 404, got %d", method, target, w.Code)
+		}
+	}
+	if len(store.audit) != audited {
+		t.Errorf("want no audit log for missing torrents")
+	}
+}
+
+func TestAdminTorrentLabelsHandler(t *testing.T) {
+	store, torrent := newAdminStore()
+	server := &Server{config: &ServerConfig{}, store: store}
+	r := newAdminRouter(server)
+	target := "/api/admin/torrents/" + torrent.ID.String()
+
+	patch := func(body string) *httptest.ResponseRecorder {
+		t.Helper()
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPatch, target, strings.NewReader(body)))
+		return w
+	}
+
+	w := patch(`{"name":"  Ubuntu 24.04  ","category":"linux","tags":["ISO"," amd64","iso",""]}`)
+	var updated Torrent
+	json.Unmarshal(w.Body.Bytes(), &updated)
+	if w.Code != http.StatusOK || updated.Name != "Ubuntu 24.04" || updated.Category != "linux" || !slices.Equal(updated.Tags, []string{"iso", "amd64"}) {
+		t.Fatalf("want trimmed labels, got %d %s", w.Code, w.Body)
+	}
+	a := store.audit[len(store.audit)-1]
+	if a.Action != "torrent.labels" || a.OldValue.(map[string]any)["name"] != "ubuntu.iso" || a.NewValue.(map[string]any)["category"] != "linux" {
+		t.Errorf("unexpected audit %+v", a)
+	}
+
+	// omitted fields are kept, empty ones are cleared
+	w = patch(`{"category":""}`)
+	got := store.torrents[torrent.ID]
+	if w.Code != http.StatusOK || got.Name != "Ubuntu 24.04" || got.Category != "" || !slices.Equal(got.Tags, []string{"iso", "amd64"}) {
+		t.Errorf("want only category cleared, got %d %+v", w.Code, got)
+	}
+	w = patch(`{"name":"","tags":[]}`)
+	got = store.torrents[torrent.ID]
+	if w.Code != http.StatusOK || got.Name != "" || len(got.Tags) != 0 {
+		t.Errorf("want name and tags cleared, got %d %+v", w.Code, got)
+	}
+
+	audited := len(store.audit)
+	for _, body := range []string{
+		`{"name":"` + strings.Repeat("a", 256) + `"}`,
+		`{"category":"Linux OS"}`,
+		`{"tags":["ok","not ok"]}`,
+		`labels`,
+	} {
+		if w := patch(body); w.Code != http.StatusBadRequest {
+			t.Errorf("%.40s: want 400, got %d", body, w.Code)
+		}
+	}
+	if len(store.audit) != audited || store.torrents[torrent.ID].Name != "" {
+		t.Errorf("want invalid labels rejected without changes")
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPatch, "/api/admin/torrents/"+uuid.Must(uuid.NewV4()).String(), strings.NewReader(`{"name":"x"}`)))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("want 404 for missing torrent, got %d", w.Code)
+	}
+}
+
+func TestHiddenTorrentNotFound(t *testing.T) {
+	store, torrent := newAdminStore()
+	server := &Server{config: NewServerConfig("", "", "", "templates"), store: store}
+
+	r := mux.NewRouter()
+	r.Handle("/torrent/{id}", TorrentHandler(server))
+	r.Handle("/api/v1/torrents/{id}", APITorrentHandler(server))
+	r.Handle("/api/v1/torrents/{id}/peers", APIPeersHandler(server))
+
+	for _, target := range []string{
+		"/torrent/" + torrent.ID.String(),
+		"/api/v1/torrents/" + torrent.ID.String(),
+		"/api/v1/torrents/6161616161616161616161616161616161616161",
+		"/api/v1/torrents/" + torrent.ID.String() + "/peers",
+	} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+		if w.Code != http.StatusNotFound {
+			t.Errorf("%s: want 404 for hidden torrent, got %d", target, w.Code)
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"errors"
 	"math"
+	"math/rand"
 	"net"
 	"net/http"
 	"slices"
@@ -11,14 +12,18 @@ import (
 
 	"github.com/cristalhq/bencode"
 	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
 	"github.com/jackc/pgx/v5"
-	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/rs/zerolog/log"
 	"github.com/salimnassim/tracker/metric"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Writes statusCode header and bencoded v.
 func replyBencode(w http.ResponseWriter, v any, statusCode int) {
+	if failure, ok := v.(ErrorResponse); ok {
+		metric.TrackerFailure.WithLabelValues(failure.FailureReason).Inc()
+	}
 	bytes, err := bencode.Marshal(v)
 	if err != nil {
 		log.Error().Err(err).Msg("cant bencode ok reply")
@@ -29,14 +34,67 @@ func replyBencode(w http.ResponseWriter, v any, statusCode int) {
 	w.Write(bytes)
 }
 
+// Returns the client IP of r.
+// X-Forwarded-For takes precedence over the remote address.
+func remoteIP(r *http.Request) (string, error) {
+	if r.Header.Get("X-Forwarded-For") != "" {
+		return r.Header.Get("X-Forwarded-For"), nil
+	}
+
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return "", err
+	}
+
+	if ip == "::1" {
+		ip = "127.0.0.1"
+	}
+
+	return ip, nil
+}
+
+// Reports whether req should be written to the announce log.
+// Events are always logged, regular announces are sampled.
+func (sv *Server) sampleLog(req AnnounceRequest) bool {
+	if req.Event != "" {
+		return true
+	}
+	return rand.Float64() < sv.config.LogSampleRate
+}
+
+// Checks the passkey in the route of r in private mode and replies with a failure if it is not valid.
+// Returns false if the request should not be handled.
+func (sv *Server) checkPasskey(w http.ResponseWriter, r *http.Request) bool {
+	if !sv.config.Private {
+		return true
+	}
+
+	_, err := sv.store.UserByPasskey(r.Context(), mux.Vars(r)["passkey"])
+	if errors.Is(err, pgx.ErrNoRows) {
+		replyBencode(w, ErrorResponse{FailureReason: "passkey is not valid"}, http.StatusForbidden)
+		return false
+	}
+	if err != nil {
+		log.Ctx(r.Context()).Error().Err(err).Str("source", "http_announce").Msg("cant get user by passkey")
+		w.WriteHeader(http.StatusInternalServerError)
+		return false
+	}
+
+	return true
+}
+
 func AnnounceHandler(server *Server) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
+
+		if !server.checkPasskey(w, r) {
+			return
+		}
 		query := r.URL.Query()
 
-		ip, _, err := net.SplitHostPort(r.RemoteAddr)
+		ip, err := remoteIP(r)
 		if err != nil {
-			log.Error().Err(err).Str("source", "http_announce").Msg("cant split host port")
+			log.Ctx(ctx).Error().Err(err).Str("source", "http_announce").Msg("cant split host port")
 			failure := ErrorResponse{
 				FailureReason: "internal server error",
 			}
@@ -44,14 +102,6 @@ func AnnounceHandler(server *Server) http.HandlerFunc {
 			return
 		}
 
-		if ip == "::1" {
-			ip = "127.0.0.1"
-		}
-
-		if r.Header.Get("X-Forwarded-For") != "" {
-			ip = r.Header.Get("X-Forwarded-For")
-		}
-
 		port, err := strconv.ParseInt(query.Get("port"), 10, 0)
 		if err != nil {
 			failure := ErrorResponse{
@@ -104,7 +154,7 @@ func AnnounceHandler(server *Server) http.HandlerFunc {
 		// has to be exactly 20 bytes
 		infoHash := []byte(query.Get("info_hash"))
 		if len(infoHash) != 20 {
-			log.Info().Str("source", "http_announce").Msgf("client info hash is not 20 bytes: %s", infoHash)
+			log.Ctx(ctx).Info().Str("source", "http_announce").Msgf("client info hash is not 20 bytes: %s", infoHash)
 			failure := ErrorResponse{
 				FailureReason: "info_hash is not valid",
 			}
@@ -115,7 +165,7 @@ func AnnounceHandler(server *Server) http.HandlerFunc {
 		// has to be exactly 20 bytes
 		peerID := []byte(query.Get("peer_id"))
 		if len(peerID) != 20 {
-			log.Info().Str("source", "http_announce").Msgf("client peer id is not 20 bytes: %s", peerID)
+			log.Ctx(ctx).Info().Str("source", "http_announce").Msgf("client peer id is not 20 bytes: %s", peerID)
 			failure := ErrorResponse{
 				FailureReason: "peer_id is not valid",
 			}
@@ -135,6 +185,8 @@ func AnnounceHandler(server *Server) http.HandlerFunc {
 			Left:       int(left),
 		}
 
+		trace.SpanFromContext(ctx).SetAttributes(infoHashAttr(req.InfoHash), eventAttr(req.Event))
+
 		err = server.validator.Struct(req)
 		if err != nil {
 			errors := err.(validator.ValidationErrors)
@@ -150,52 +202,65 @@ func AnnounceHandler(server *Server) http.HandlerFunc {
 			return
 		}
 
-		err = server.store.Log(ctx, req)
-		if err != nil {
-			log.Error().Err(err).Str("source", "http_announce").Msg("cant insert announce log")
-			w.WriteHeader(http.StatusInternalServerError)
-			return
+		if server.sampleLog(req) {
+			err = server.store.Log(ctx, req)
+			if err != nil {
+				log.Ctx(ctx).Error().Err(err).Str("source", "http_announce").Msg("cant insert announce log")
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
 		}
 
 		metric.TrackerAnnounce.Inc()
 
 		var torrent Torrent
-
-		// get torrent
-		torrent, err = server.store.Torrent(ctx, []byte(req.InfoHash))
-		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
-			log.Error().Err(err).Str("source", "http_announce").Msg("cant get torrent")
-			w.WriteHeader(http.StatusInternalServerError)
-			return
-		}
-
-		// create torrent not found as we track all announced
-		if torrent.ID.IsNil() && errors.Is(err, pgx.ErrNoRows) {
-			torrent, err = server.store.AddTorrent(ctx, []byte(req.InfoHash))
-
-			metric.TrackerTorrents.Inc()
-			if err != nil {
-				var pgError *pgconn.PgError
-				if errors.As(err, &pgError) {
-					if pgError.Code == "23505" {
-						log.Error().Err(err).Str("source", "http_announce").Msg("duplicate torrent on insert")
-						w.WriteHeader(http.StatusInternalServerError)
-						return
-					}
+		if server.config.Private {
+			// only torrents with uploaded metainfo are registered
+			torrent, err = server.store.Torrent(ctx, req.InfoHash)
+			if errors.Is(err, pgx.ErrNoRows) || (err == nil && !torrent.Uploaded) {
+				failure := ErrorResponse{
+					FailureReason: "torrent is not registered",
 				}
-				log.Error().Err(err).Str("source", "http_announce").Msg("cant add torrent to store")
+				replyBencode(w, failure, http.StatusNotFound)
+				return
+			}
+			if err != nil {
+				log.Ctx(ctx).Error().Err(err).Str("source", "http_announce").Msg("cant get torrent")
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+		} else {
+			// get or create torrent as we track all announced
+			var created bool
+			torrent, created, err = server.store.GetOrAddTorrent(ctx, req.InfoHash)
+			if err != nil {
+				log.Ctx(ctx).Error().Err(err).Str("source", "http_announce").Msg("cant get or add torrent")
 				w.WriteHeader(http.StatusInternalServerError)
 				return
 			}
+			if created {
+				metric.TrackerTorrents.Inc()
+			}
 		}
 
+		if torrent.HasFlag(TorrentFrozen) {
+			failure := ErrorResponse{
+				FailureReason: "torrent is frozen",
+			}
+			replyBencode(w, failure, http.StatusForbidden)
+			return
+		}
+
+		// only accepted announces count towards the announce rate in stats
+		server.announces.Add(1)
+
 		// try to update existing record by using query string key
 		// ok is true if peer was updated with a key
 		var ok bool
 		if query.Get("key") != "" {
 			ok, err = server.store.UpdatePeerWithKey(ctx, torrent.ID, req)
 			if err != nil {
-				log.Error().Err(err).Str("source", "http_announce").Msg("cant update peer with key")
+				log.Ctx(ctx).Error().Err(err).Str("source", "http_announce").Msg("cant update peer with key")
 				failure := ErrorResponse{
 					FailureReason: "key is not valid",
 				}
@@ -207,7 +272,7 @@ func AnnounceHandler(server *Server) http.HandlerFunc {
 		if !ok {
 			err = server.store.UpsertPeer(ctx, torrent.ID, req)
 			if err != nil {
-				log.Error().Err(err).Str("source", "http_announce").Msg("cant upsert peer")
+				log.Ctx(ctx).Error().Err(err).Str("source", "http_announce").Msg("cant upsert peer")
 				w.WriteHeader(http.StatusInternalServerError)
 				return
 			}
@@ -217,7 +282,7 @@ func AnnounceHandler(server *Server) http.HandlerFunc {
 		if req.Event == "completed" {
 			err := server.store.IncrementTorrent(ctx, torrent.ID)
 			if err != nil {
-				log.Error().Err(err).Str("source", "http_announce").Msg("cant increment torrent completed")
+				log.Ctx(ctx).Error().Err(err).Str("source", "http_announce").Msg("cant increment torrent completed")
 				w.WriteHeader(http.StatusInternalServerError)
 				return
 			}
@@ -225,7 +290,7 @@ func AnnounceHandler(server *Server) http.HandlerFunc {
 
 		peers, err := server.store.Peers(ctx, torrent.ID)
 		if err != nil {
-			log.Error().Err(err).Str("source", "http_announce").Msg("cant get peers")
+			log.Ctx(ctx).Error().Err(err).Str("source", "http_announce").Msg("cant get peers")
 			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
@@ -234,13 +299,13 @@ func AnnounceHandler(server *Server) http.HandlerFunc {
 		for _, p := range peers {
 			pm, err := p.Marshal()
 			if err != nil {
-				log.Error().Err(err).Str("source", "http_announce").Msg("cant marshal peer")
+				log.Ctx(ctx).Error().Err(err).Str("source", "http_announce").Msg("cant marshal peer")
 				w.WriteHeader(http.StatusInternalServerError)
 				return
 			}
 			_, err = buffer.Write(pm)
 			if err != nil {
-				log.Error().Err(err).Str("source", "http_announce").Msg("cant write marshalled peer to buffer")
+				log.Ctx(ctx).Error().Err(err).Str("source", "http_announce").Msg("cant write marshalled peer to buffer")
 				w.WriteHeader(http.StatusInternalServerError)
 				return
 			}
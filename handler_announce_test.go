@@ -0,0 +1,171 @@
+package tracker
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofrs/uuid"
+	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v5"
+)
+
+func TestAnnounceConcurrentNewTorrent(t *testing.T) {
+	server := newTestServer(t)
+	handler := AnnounceHandler(server)
+
+	infoHash := make([]byte, 20)
+	_, err := rand.Read(infoHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		ctx := context.Background()
+		torrent, err := server.store.Torrent(ctx, infoHash)
+		if err == nil {
+			server.store.DeleteTorrent(ctx, torrent.ID)
+		}
+	})
+
+	const n = 16
+	codes := make([]int, n)
+
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			query := url.Values{}
+			query.Set("info_hash", string(infoHash))
+			query.Set("peer_id", fmt.Sprintf("-TR3000-%012d", i))
+			query.Set("port", "6881")
+			query.Set("uploaded", "0")
+			query.Set("downloaded", "0")
+			query.Set("left", "100")
+			query.Set("event", "started")
+
+			r := httptest.NewRequest(http.MethodGet, "/announce?"+query.Encode(), nil)
+			w := httptest.NewRecorder()
+
+			<-start
+			handler.ServeHTTP(w, r)
+			codes[i] = w.Code
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+
+	for i, code := range codes {
+		if code != http.StatusOK {
+			t.Errorf("announce %d: want: %v, got %v", i, http.StatusOK, code)
+		}
+	}
+
+	torrent, err := server.store.Torrent(context.Background(), infoHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if torrent.Leechers != n {
+		t.Errorf("want: %v leechers, got %v", n, torrent.Leechers)
+	}
+}
+
+// Serves fixed torrents and one passkey to announces in private mode.
+type announceStore struct {
+	TorrentStorable
+	torrents []Torrent
+}
+
+func (s *announceStore) UserByPasskey(ctx context.Context, passkey string) (User, error) {
+	if passkey != "abc123" {
+		return User{}, pgx.ErrNoRows
+	}
+	return User{Username: "alice", Passkey: passkey}, nil
+}
+
+func (s *announceStore) Log(ctx context.Context, req AnnounceRequest) error {
+	return nil
+}
+
+func (s *announceStore) Torrent(ctx context.Context, infoHash []byte) (Torrent, error) {
+	for _, t := range s.torrents {
+		if string(t.InfoHash) == string(infoHash) {
+			return t, nil
+		}
+	}
+	return Torrent{}, pgx.ErrNoRows
+}
+
+func TestAnnounceRejectedNotCounted(t *testing.T) {
+	config := NewServerConfig("", "http://localhost:9999/announce", "", "templates")
+	config.Private = true
+	server := &Server{
+		config:    config,
+		validator: validator.New(),
+		store: &announceStore{torrents: []Torrent{
+			{ID: uuid.Must(uuid.NewV4()), InfoHash: []byte("aaaaaaaaaaaaaaaaaaaa")},
+			{ID: uuid.Must(uuid.NewV4()), InfoHash: []byte("bbbbbbbbbbbbbbbbbbbb"), Uploaded: true, Flags: []string{TorrentFrozen}},
+		}},
+	}
+	r := mux.NewRouter()
+	HandleTracker(r, server)
+
+	for _, tc := range []struct {
+		infoHash string
+		status   int
+		reason   string
+	}{
+		{"aaaaaaaaaaaaaaaaaaaa", http.StatusNotFound, "torrent is not registered"},
+		{"cccccccccccccccccccc", http.StatusNotFound, "torrent is not registered"},
+		{"bbbbbbbbbbbbbbbbbbbb", http.StatusForbidden, "torrent is frozen"},
+	} {
+		query := url.Values{}
+		query.Set("info_hash", tc.infoHash)
+		query.Set("peer_id", "-TR3000-000000000001")
+		query.Set("port", "6881")
+		query.Set("uploaded", "0")
+		query.Set("downloaded", "0")
+		query.Set("left", "100")
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/announce/abc123?"+query.Encode(), nil))
+		if w.Code != tc.status || !strings.Contains(w.Body.String(), tc.reason) {
+			t.Errorf("%s: want %d %q, got %d %s", tc.infoHash, tc.status, tc.reason, w.Code, w.Body)
+		}
+	}
+	if n := server.announces.Load(); n != 0 {
+		t.Errorf("want rejected announces not counted, got %d", n)
+	}
+}
+
+func TestSampleLog(t *testing.T) {
+	server := &Server{config: NewServerConfig("", "", "", "")}
+
+	server.config.LogSampleRate = 0
+	for _, event := range []string{"started", "stopped", "completed"} {
+		if !server.sampleLog(AnnounceRequest{Event: event}) {
+			t.Errorf("want %s always logged", event)
+		}
+	}
+	for i := 0; i < 100; i++ {
+		if server.sampleLog(AnnounceRequest{}) {
+			t.Fatal("want regular announces not logged at rate 0")
+		}
+	}
+
+	server.config.LogSampleRate = 1
+	for i := 0; i < 100; i++ {
+		if !server.sampleLog(AnnounceRequest{}) {
+			t.Fatal("want regular announces logged at rate 1")
+		}
+	}
+}
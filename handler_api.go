@@ -0,0 +1,306 @@
+package tracker
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+)
+
+type APIError struct {
+	Status  int    `json:"status"`
+	Message string `json:"message"`
+}
+
+type APIErrorResponse struct {
+	Error APIError `json:"error"`
+}
+
+type APITorrentsResponse struct {
+	Torrents []Torrent `json:"torrents"`
+	Total    int       `json:"total"`
+	Page     int       `json:"page"`
+	Limit    int       `json:"limit"`
+}
+
+type APIPeer struct {
+	ID         string    `json:"id"`
+	PeerID     string    `json:"peer_id"`
+	Client     string    `json:"client"`
+	IP         string    `json:"ip"`
+	Port       int       `json:"port"`
+	Uploaded   int       `json:"uploaded"`
+	Downloaded int       `json:"downloaded"`
+	Left       int       `json:"left"`
+	Event      string    `json:"event"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+func newAPIPeer(p Peer, mask bool) APIPeer {
+	ip := p.IP.String()
+	if mask {
+		ip = maskIP(p.IP)
+	}
+	return APIPeer{
+		ID:         p.ID.String(),
+		PeerID:     hex.EncodeToString(p.PeerID),
+		Client:     p.Client(),
+		IP:         ip,
+		Port:       p.Port,
+		Uploaded:   p.Uploaded,
+		Downloaded: p.Downloaded,
+		Left:       p.Left,
+		Event:      p.Event,
+		UpdatedAt:  p.UpdatedAt,
+	}
+}
+
+type APIPeersResponse struct {
+	Peers []APIPeer `json:"peers"`
+	Page  int       `json:"page"`
+	Limit int       `json:"limit"`
+}
+
+// Writes statusCode header and v as JSON.
+func replyJSON(w http.ResponseWriter, v any, statusCode int) {
+	bytes, err := json.Marshal(v)
+	if err != nil {
+		log.Error().Err(err).Msg("cant marshal json reply")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	w.Write(bytes)
+}
+
+// Writes statusCode header and an error object with message.
+func replyJSONError(w http.ResponseWriter, message string, statusCode int) {
+	replyJSON(w, APIErrorResponse{
+		Error: APIError{
+			Status:  statusCode,
+			Message: message,
+		},
+	}, statusCode)
+}
+
+// Parses page and limit from query string.
+// Page starts from 1, limit defaults to 50 and is at most 500.
+func parsePage(r *http.Request) (page int, limit int, err error) {
+	query := r.URL.Query()
+	page, limit = 1, 50
+
+	if v := query.Get("page"); v != "" {
+		page, err = strconv.Atoi(v)
+		if err != nil || page < 1 {
+			return 0, 0, errors.New("page is not valid")
+		}
+	}
+	if v := query.Get("limit"); v != "" {
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > 500 {
+			return 0, 0, errors.New("limit is not valid")
+		}
+	}
+
+	return page, limit, nil
+}
+
+// Parses search, category and tag filters from query string.
+func parseTorrentFilter(r *http.Request, q *TorrentQuery) error {
+	query := r.URL.Query()
+
+	q.Search = strings.TrimSpace(query.Get("q"))
+	if len(q.Search) > 200 {
+		return errors.New("q is too long")
+	}
+
+	q.Category = query.Get("category")
+	if q.Category != "" && !ValidLabel(q.Category) {
+		return errors.New("category is not valid")
+	}
+
+	q.Tags = nil
+	for _, tag := range query["tag"] {
+		if !ValidLabel(tag) {
+			return errors.New("tag is not valid")
+		}
+		q.Tags = append(q.Tags, tag)
+	}
+
+	return nil
+}
+
+// Gets torrent by UUID or hex encoded info hash in the route of r.
+// Hidden torrents are not found unless withHidden is set, e.g. for moderators.
+func torrentFromVars(server *Server, r *http.Request, withHidden bool) (Torrent, error) {
+	torrent, err := torrentFromID(server, r.Context(), mux.Vars(r)["id"])
+	if err == nil && !withHidden && torrent.HasFlag(TorrentHidden) {
+		return Torrent{}, pgx.ErrNoRows
+	}
+	return torrent, err
+}
+
+// Gets torrent by UUID or hex encoded info hash.
+func torrentFromID(server *Server, ctx context.Context, id string) (Torrent, error) {
+	if len(id) == 40 {
+		infoHash, err := hex.DecodeString(id)
+		if err == nil {
+			return server.store.Torrent(ctx, infoHash)
+		}
+	}
+
+	torrentID, err := uuid.FromString(id)
+	if err != nil {
+		return Torrent{}, pgx.ErrNoRows
+	}
+	return server.store.TorrentByID(ctx, torrentID)
+}
+
+// Masks the host part of ip, keeping the /24 of IPv4 and /48 of IPv6.
+func maskIP(ip net.IP) string {
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4.Mask(net.CIDRMask(24, 32)).String()
+	}
+	return ip.Mask(net.CIDRMask(48, 128)).String()
+}
+
+func APITorrentsHandler(server *Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		query := r.URL.Query()
+
+		page, limit, err := parsePage(r)
+		if err != nil {
+			replyJSONError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		q := TorrentQuery{
+			Sort:   "created_at",
+			Order:  "desc",
+			Limit:  limit,
+			Offset: (page - 1) * limit,
+			Count:  true,
+		}
+		if v := query.Get("sort"); v != "" {
+			if !slices.Contains(torrentSorts, v) {
+				replyJSONError(w, "sort is not valid", http.StatusBadRequest)
+				return
+			}
+			q.Sort = v
+		}
+		if v := query.Get("order"); v != "" {
+			if v != "asc" && v != "desc" {
+				replyJSONError(w, "order is not valid", http.StatusBadRequest)
+				return
+			}
+			q.Order = v
+		}
+		err = parseTorrentFilter(r, &q)
+		if err != nil {
+			replyJSONError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		torrents, total, err := server.store.ListTorrents(ctx, q)
+		if err != nil {
+			log.Ctx(ctx).Error().Err(err).Str("source", "http_api").Msg("cant list torrents")
+			replyJSONError(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		if torrents == nil {
+			torrents = []Torrent{}
+		}
+
+		replyJSON(w, APITorrentsResponse{
+			Torrents: torrents,
+			Total:    total,
+			Page:     page,
+			Limit:    limit,
+		}, http.StatusOK)
+	}
+}
+
+func APITorrentHandler(server *Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		torrent, err := torrentFromVars(server, r, false)
+		if errors.Is(err, pgx.ErrNoRows) {
+			replyJSONError(w, "torrent not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Ctx(r.Context()).Error().Err(err).Str("source", "http_api").Msg("cant get torrent")
+			replyJSONError(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		replyJSON(w, &torrent, http.StatusOK)
+	}
+}
+
+func APIPeersHandler(server *Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		page, limit, err := parsePage(r)
+		if err != nil {
+			replyJSONError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		torrent, err := torrentFromVars(server, r, false)
+		if errors.Is(err, pgx.ErrNoRows) {
+			replyJSONError(w, "torrent not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Ctx(ctx).Error().Err(err).Str("source", "http_api").Msg("cant get torrent")
+			replyJSONError(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		peers, err := server.store.ListPeers(ctx, torrent.ID, limit, (page-1)*limit)
+		if err != nil {
+			log.Ctx(ctx).Error().Err(err).Str("source", "http_api").Msg("cant list peers")
+			replyJSONError(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		res := APIPeersResponse{
+			Peers: make([]APIPeer, 0, len(peers)),
+			Page:  page,
+			Limit: limit,
+		}
+		for _, p := range peers {
+			res.Peers = append(res.Peers, newAPIPeer(p, server.config.APIMaskIPs))
+		}
+
+		replyJSON(w, res, http.StatusOK)
+	}
+}
+
+func APIStatsHandler(server *Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		stats, err := server.Stats(r.Context())
+		if err != nil {
+			log.Ctx(r.Context()).Error().Err(err).Str("source", "http_api").Msg("cant get stats")
+			replyJSONError(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		replyJSON(w, stats, http.StatusOK)
+	}
+}
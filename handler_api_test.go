@@ -0,0 +1,298 @@
+package tracker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v5"
+)
+
+// Serves fixed data to the API handlers.
+type apiStore struct {
+	TorrentStorable
+	torrent Torrent
+	peer    Peer
+}
+
+func newAPIStore() *apiStore {
+	torrentID := uuid.Must(uuid.NewV4())
+	return &apiStore{
+		torrent: Torrent{
+			ID:        torrentID,
+			InfoHash:  []byte("aaaaaaaaaaaaaaaaaaaa"),
+			Completed: 3,
+			CreatedAt: time.Now(),
+			Seeders:   1,
+			Leechers:  2,
+		},
+		peer: Peer{
+			ID:        uuid.Must(uuid.NewV4()),
+			TorrentID: torrentID,
+			PeerID:    []byte("-TR3000-dybw6lsnsc17"),
+			Port:      6881,
+			IP:        net.IPv4(127, 0, 0, 1),
+			UpdatedAt: time.Now(),
+			Event:     "started",
+		},
+	}
+}
+
+func (s *apiStore) Torrent(ctx context.Context, infoHash []byte) (Torrent, error) {
+	if string(infoHash) != string(s.torrent.InfoHash) {
+		return Torrent{}, pgx.ErrNoRows
+	}
+	return s.torrent, nil
+}
+
+func (s *apiStore) TorrentByID(ctx context.Context, torrentID uuid.UUID) (Torrent, error) {
+	if torrentID != s.torrent.ID {
+		return Torrent{}, pgx.ErrNoRows
+	}
+	return s.torrent, nil
+}
+
+func (s *apiStore) ListTorrents(ctx context.Context, query TorrentQuery) ([]Torrent, int, error) {
+	return []Torrent{s.torrent}, 1, nil
+}
+
+func (s *apiStore) ListPeers(ctx context.Context, torrentID uuid.UUID, limit int, offset int) ([]Peer, error) {
+	return []Peer{s.peer}, nil
+}
+
+func (s *apiStore) Stats(ctx context.Context) (Stats, error) {
+	return Stats{Torrents: 1, Peers: 3, Seeders: 1, Leechers: 2, Completed: 3}, nil
+}
+
+// Validates v against a subset of JSON schema used by openapi.json.
+// Objects must have their required properties and nothing else if
+// additionalProperties is false.
+func validateSchema(spec map[string]any, schema map[string]any, v any, path string) error {
+	if ref, ok := schema["$ref"].(string); ok {
+		resolved, err := resolveRef(spec, ref)
+		if err != nil {
+			return err
+		}
+		return validateSchema(spec, resolved, v, path)
+	}
+
+	switch schema["type"] {
+	case "object":
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s: want object, got %T", path, v)
+		}
+		properties, _ := schema["properties"].(map[string]any)
+		required, _ := schema["required"].([]any)
+		for _, name := range required {
+			if _, ok := obj[name.(string)]; !ok {
+				return fmt.Errorf("%s: missing required property %q", path, name)
+			}
+		}
+		for name, value := range obj {
+			property, ok := properties[name].(map[string]any)
+			if !ok {
+				if schema["additionalProperties"] == false {
+					return fmt.Errorf("%s: property %q is not in the spec", path, name)
+				}
+				continue
+			}
+			err := validateSchema(spec, property, value, path+"."+name)
+			if err != nil {
+				return err
+			}
+		}
+	case "array":
+		arr, ok := v.([]any)
+		if !ok {
+			return fmt.Errorf("%s: want array, got %T", path, v)
+		}
+		items, _ := schema["items"].(map[string]any)
+		for i, item := range arr {
+			err := validateSchema(spec, items, item, path+"["+strconv.Itoa(i)+"]")
+			if err != nil {
+				return err
+			}
+		}
+	case "string":
+		s, ok := v.(string)
+		if !ok {
+			return fmt.Errorf("%s: want string, got %T", path, v)
+		}
+		switch schema["format"] {
+		case "date-time":
+			_, err := time.Parse(time.RFC3339, s)
+			if err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+		case "uuid":
+			_, err := uuid.FromString(s)
+			if err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+		}
+	case "integer":
+		n, ok := v.(float64)
+		if !ok || n != float64(int64(n)) {
+			return fmt.Errorf("%s: want integer, got %v", path, v)
+		}
+	case "number":
+		if _, ok := v.(float64); !ok {
+			return fmt.Errorf("%s: want number, got %T", path, v)
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return fmt.Errorf("%s: want boolean, got %T", path, v)
+		}
+	default:
+		return fmt.Errorf("%s: unsupported schema type %v", path, schema["type"])
+	}
+	return nil
+}
+
+// Resolves a local reference like #/components/schemas/Torrent.
+func resolveRef(spec map[string]any, ref string) (map[string]any, error) {
+	node := spec
+	for _, part := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+		next, ok := node[part].(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("cant resolve %s", ref)
+		}
+		node = next
+	}
+	return node, nil
+}
+
+// Returns the response schema of the spec operation for status.
+func responseSchema(spec map[string]any, path string, method string, status int) (map[string]any, error) {
+	paths := spec["paths"].(map[string]any)
+	operation, ok := paths[path].(map[string]any)[method].(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("%s %s is not in the spec", method, path)
+	}
+	response, ok := operation["responses"].(map[string]any)[strconv.Itoa(status)].(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("%s %s has no %d response in the spec", method, path, status)
+	}
+	if ref, ok := response["$ref"].(string); ok {
+		var err error
+		response, err = resolveRef(spec, ref)
+		if err != nil {
+			return nil, err
+		}
+	}
+	content := response["content"].(map[string]any)["application/json"].(map[string]any)
+	return content["schema"].(map[string]any), nil
+}
+
+func TestParseTorrentFilter(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/?q=+ubuntu+-server+&category=linux&tag=iso&tag=amd64", nil)
+	var q TorrentQuery
+	err := parseTorrentFilter(r, &q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.Search != "ubuntu -server" || q.Category != "linux" || !slices.Equal(q.Tags, []string{"iso", "amd64"}) {
+		t.Errorf("unexpected query %+v", q)
+	}
+
+	for _, query := range []string{"category=Linux", "tag=a%20b", "q=" + strings.Repeat("a", 201)} {
+		r := httptest.NewRequest(http.MethodGet, "/?"+query, nil)
+		if err := parseTorrentFilter(r, &q); err == nil {
+			t.Errorf("%s: want error", query)
+		}
+	}
+}
+
+func TestAPIMatchesOpenAPI(t *testing.T) {
+	var spec map[string]any
+	err := json.Unmarshal(openAPISpec, &spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := newAPIStore()
+	server := &Server{
+		config: &ServerConfig{APIMaskIPs: true},
+		store:  store,
+	}
+
+	r := mux.NewRouter()
+	api := r.PathPrefix("/api/v1").Subrouter()
+	api.Handle("/torrents", APITorrentsHandler(server)).Methods(http.MethodGet)
+	api.Handle("/torrents/{id}", APITorrentHandler(server)).Methods(http.MethodGet)
+	api.Handle("/torrents/{id}/peers", APIPeersHandler(server)).Methods(http.MethodGet)
+	api.Handle("/stats", APIStatsHandler(server)).Methods(http.MethodGet)
+
+	id := store.torrent.ID.String()
+	hash := fmt.Sprintf("%x", store.torrent.InfoHash)
+	missing := uuid.Must(uuid.NewV4()).String()
+
+	tests := []struct {
+		url    string
+		path   string
+		status int
+	}{
+		{"/api/v1/torrents", "/torrents", http.StatusOK},
+		{"/api/v1/torrents?sort=seeders&order=asc&page=2&limit=10", "/torrents", http.StatusOK},
+		{"/api/v1/torrents?sort=name", "/torrents", http.StatusBadRequest},
+		{"/api/v1/torrents?q=ubuntu+-server&category=linux&tag=iso&tag=amd64", "/torrents", http.StatusOK},
+		{"/api/v1/torrents?tag=Not+Valid", "/torrents", http.StatusBadRequest},
+		{"/api/v1/torrents/" + id, "/torrents/{id}", http.StatusOK},
+		{"/api/v1/torrents/" + hash, "/torrents/{id}", http.StatusOK},
+		{"/api/v1/torrents/" + missing, "/torrents/{id}", http.StatusNotFound},
+		{"/api/v1/torrents/" + id + "/peers", "/torrents/{id}/peers", http.StatusOK},
+		{"/api/v1/torrents/" + id + "/peers?limit=0", "/torrents/{id}/peers", http.StatusBadRequest},
+		{"/api/v1/torrents/" + missing + "/peers", "/torrents/{id}/peers", http.StatusNotFound},
+		{"/api/v1/stats", "/stats", http.StatusOK},
+	}
+
+	tested := []string{}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.url, nil))
+
+			if w.Code != tt.status {
+				t.Fatalf("want: %v, got %v: %s", tt.status, w.Code, w.Body.String())
+			}
+			if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+				t.Errorf("want: application/json, got %v", ct)
+			}
+
+			schema, err := responseSchema(spec, tt.path, "get", tt.status)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var body any
+			err = json.Unmarshal(w.Body.Bytes(), &body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = validateSchema(spec, schema, body, "$")
+			if err != nil {
+				t.Errorf("response does not match spec: %v\n%s", err, w.Body.String())
+			}
+		})
+		tested = append(tested, tt.path)
+	}
+
+	// every path in the spec has to be tested
+	for path := range spec["paths"].(map[string]any) {
+		if !slices.Contains(tested, path) {
+			t.Errorf("%s is in the spec but not tested", path)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package tracker
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"path/filepath"
+	"strconv"
+
+	"github.com/rs/zerolog/log"
+)
+
+type AdminAuditLogResponse struct {
+	Entries []AuditLog `json:"entries"`
+}
+
+// Audit log entry as shown on the audit page.
+type auditLogView struct {
+	AuditLog
+	OldValueText string
+	NewValueText string
+	DetailsText  string
+}
+
+func newAuditLogView(l AuditLog) auditLogView {
+	text := func(v any) string {
+		if v == nil {
+			return ""
+		}
+		b, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(b)
+	}
+	view := auditLogView{
+		AuditLog:     l,
+		OldValueText: text(l.OldValue),
+		NewValueText: text(l.NewValue),
+	}
+	if len(l.Details) > 0 {
+		view.DetailsText = text(l.Details)
+	}
+	return view
+}
+
+// Parses audit log filter from query string.
+// from and to are RFC 3339 or datetime-local in UTC.
+func parseAuditLogFilter(r *http.Request) (AuditLogFilter, error) {
+	query := r.URL.Query()
+	filter := AuditLogFilter{
+		Actor:  query.Get("actor"),
+		Action: query.Get("action"),
+		Target: query.Get("target"),
+		Limit:  100,
+	}
+
+	if v := query.Get("from"); v != "" {
+		from, err := parseQueryTime(v)
+		if err != nil {
+			return filter, fmt.Errorf("from is not valid")
+		}
+		filter.From = from
+	}
+	if v := query.Get("to"); v != "" {
+		to, err := parseQueryTime(v)
+		if err != nil {
+			return filter, fmt.Errorf("to is not valid")
+		}
+		filter.To = to
+	}
+
+	if v := query.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > 1000 {
+			return filter, fmt.Errorf("limit is not valid")
+		}
+		filter.Limit = limit
+	}
+
+	return filter, nil
+}
+
+// Searches the audit log and renders a page.
+func AuditLogHandler(server *Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		filter, err := parseAuditLogFilter(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		logs, err := server.store.AuditLogs(ctx, filter)
+		if err != nil {
+			log.Ctx(ctx).Error().Err(err).Msg("cant get audit logs in audit")
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		views := make([]auditLogView, 0, len(logs))
+		for _, l := range logs {
+			views = append(views, newAuditLogView(l))
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+
+		tmpl, err := template.ParseFiles(filepath.Join(server.config.TemplatePath, "audit.html"))
+		if err != nil {
+			log.Ctx(ctx).Error().Err(err).Msg("cant parse template in audit")
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		dto := map[string]interface{}{
+			"Logs":  views,
+			"Query": r.URL.Query(),
+		}
+
+		err = tmpl.Execute(w, dto)
+		if err != nil {
+			log.Ctx(ctx).Error().Err(err).Msg("cant execute template in audit")
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// Searches the audit log and replies with JSON.
+func AdminAuditLogHandler(server *Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		filter, err := parseAuditLogFilter(r)
+		if err != nil {
+			replyJSONError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		logs, err := server.store.AuditLogs(r.Context(), filter)
+		if err != nil {
+			log.Ctx(r.Context()).Error().Err(err).Str("source", "http_admin").Msg("cant get audit logs")
+			replyJSONError(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		if logs == nil {
+			logs = []AuditLog{}
+		}
+
+		replyJSON(w, AdminAuditLogResponse{Entries: logs}, http.StatusOK)
+	}
+}
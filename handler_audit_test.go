@@ -0,0 +1,74 @@
+package tracker
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+// Records the audit log filter and returns a fixed entry.
+type auditStore struct {
+	TorrentStorable
+	filter AuditLogFilter
+	entry  AuditLog
+}
+
+func (s *auditStore) AuditLogs(ctx context.Context, filter AuditLogFilter) ([]AuditLog, error) {
+	s.filter = filter
+	return []AuditLog{s.entry}, nil
+}
+
+func TestAdminAuditLogHandler(t *testing.T) {
+	store := &auditStore{
+		entry: AuditLog{
+			ID:        uuid.Must(uuid.NewV4()),
+			Actor:     "alice",
+			Action:    "torrent.freeze",
+			Target:    "42",
+			OldValue:  map[string]any{"flags": []string{}},
+			NewValue:  map[string]any{"flags": []string{"frozen"}},
+			Details:   map[string]any{},
+			CreatedAt: time.Now(),
+		},
+	}
+	server := &Server{config: &ServerConfig{}, store: store}
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/api/admin/audit?actor=alice&action=torrent.freeze&from=2026-10-01T00:00&limit=10", nil)
+	AdminAuditLogHandler(server).ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("want: %v, got %v: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	want := AuditLogFilter{
+		Actor:  "alice",
+		Action: "torrent.freeze",
+		From:   time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC),
+		Limit:  10,
+	}
+	if store.filter != want {
+		t.Errorf("want: %+v, got %+v", want, store.filter)
+	}
+
+	var res struct {
+		Entries []map[string]any `json:"entries"`
+	}
+	err := json.Unmarshal(w.Body.Bytes(), &res)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Entries) != 1 || res.Entries[0]["new_value"] == nil || res.Entries[0]["actor"] != "alice" {
+		t.Errorf("unexpected response %s", w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	AdminAuditLogHandler(server).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/admin/audit?from=yesterday", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("want: %v, got %v", http.StatusBadRequest, w.Code)
+	}
+}
@@ -0,0 +1,131 @@
+package tracker
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gofrs/uuid"
+	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+	"github.com/salimnassim/tracker/metainfo"
+)
+
+// Returns the announce URL with passkey appended as a path segment.
+func (c *ServerConfig) passkeyURL(passkey string) string {
+	if passkey == "" {
+		return c.AnnounceURL
+	}
+	return strings.TrimSuffix(c.AnnounceURL, "/") + "/" + url.PathEscape(passkey)
+}
+
+// Returns the announce-list of downloaded .torrent files:
+// the announce URL followed by one tier per configured tracker.
+func (c *ServerConfig) announceList(announce string) [][]string {
+	if len(c.AnnounceList) == 0 {
+		return nil
+	}
+	list := [][]string{{announce}}
+	for _, u := range c.AnnounceList {
+		list = append(list, []string{u})
+	}
+	return list
+}
+
+// Returns the download link of torrentID. The passkey is added when the
+// download would otherwise need a login, e.g. for feed readers.
+func (c *ServerConfig) downloadURL(torrentID uuid.UUID, passkey string) string {
+	u := c.publicURL() + "/torrent/" + torrentID.String() + "/download"
+	if passkey != "" && (c.Private || c.RequireLogin) {
+		u += "/" + url.PathEscape(passkey)
+	}
+	return u
+}
+
+// Serves the uploaded .torrent file with the announce URL of the tracker.
+// In private mode the announce URL has the passkey of the logged in user,
+// or of the passkey in the path for clients that cannot log in.
+func DownloadHandler(server *Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		torrentID, err := uuid.FromString(mux.Vars(r)["id"])
+		if err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		var passkey string
+		if key, ok := mux.Vars(r)["passkey"]; ok {
+			user, err := server.store.UserByPasskey(ctx, key)
+			if errors.Is(err, pgx.ErrNoRows) {
+				http.Error(w, "passkey is not valid", http.StatusNotFound)
+				return
+			}
+			if err != nil {
+				log.Ctx(ctx).Error().Err(err).Msg("cant get user by passkey in download")
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			if server.config.Private {
+				passkey = user.Passkey
+			}
+		} else if server.config.Private {
+			user, ok, err := server.sessionUser(r)
+			if err != nil {
+				log.Ctx(ctx).Error().Err(err).Msg("cant get session in download")
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			if !ok {
+				http.Redirect(w, r, "/login?next="+url.QueryEscape(r.URL.RequestURI()), http.StatusSeeOther)
+				return
+			}
+			passkey = user.Passkey
+		}
+
+		torrent, err := server.store.TorrentByID(ctx, torrentID)
+		if errors.Is(err, pgx.ErrNoRows) || (err == nil && torrent.HasFlag(TorrentHidden)) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Ctx(ctx).Error().Err(err).Msg("cant get torrent in download")
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		meta, err := server.store.MetaInfo(ctx, torrentID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Ctx(ctx).Error().Err(err).Msg("cant get metainfo in download")
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		announce := server.config.passkeyURL(passkey)
+		data, err := metainfo.Encode(meta.Info, meta.Extra, announce, server.config.announceList(announce))
+		if err != nil {
+			log.Ctx(ctx).Error().Err(err).Msg("cant encode torrent in download")
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		// keep the file name ascii and without quotes
+		name := strings.Map(func(r rune) rune {
+			if r < 0x20 || r > 0x7e || r == '"' || r == '\\' || r == '/' {
+				return '_'
+			}
+			return r
+		}, torrent.Name)
+
+		w.Header().Set("Content-Type", "application/x-bittorrent")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+name+`.torrent"`)
+		w.Write(data)
+	}
+}
@@ -0,0 +1,138 @@
+package tracker
+
+import (
+	"context"
+	"crypto/sha1"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofrs/uuid"
+	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v5"
+	"github.com/salimnassim/tracker/metainfo"
+)
+
+// Serves one uploaded torrent and one user.
+type downloadStore struct {
+	TorrentStorable
+	torrent Torrent
+	meta    TorrentMetaInfo
+	user    User
+}
+
+func (s *downloadStore) TorrentByID(ctx context.Context, torrentID uuid.UUID) (Torrent, error) {
+	if torrentID != s.torrent.ID {
+		return Torrent{}, pgx.ErrNoRows
+	}
+	return s.torrent, nil
+}
+
+func (s *downloadStore) MetaInfo(ctx context.Context, torrentID uuid.UUID) (TorrentMetaInfo, error) {
+	if torrentID != s.torrent.ID {
+		return TorrentMetaInfo{}, pgx.ErrNoRows
+	}
+	return s.meta, nil
+}
+
+func (s *downloadStore) SessionUser(ctx context.Context, hash []byte) (User, error) {
+	if string(hash) != string(HashTokenSecret("session")) {
+		return User{}, pgx.ErrNoRows
+	}
+	return s.user, nil
+}
+
+func (s *downloadStore) UserByPasskey(ctx context.Context, passkey string) (User, error) {
+	if passkey != s.user.Passkey {
+		return User{}, pgx.ErrNoRows
+	}
+	return s.user, nil
+}
+
+func TestDownload(t *testing.T) {
+	info := "d6:lengthi1000e4:name8:file.iso12:piece lengthi16384e6:pieces20:" + strings.Repeat("a", 20) + "7:privatei1ee"
+	store := &downloadStore{
+		torrent: Torrent{ID: uuid.Must(uuid.NewV4()), Name: `file "1".iso`},
+		meta:    TorrentMetaInfo{Info: []byte(info), Extra: []byte("d7:comment5:hello8:url-listl19:http://mirror/file/ee")},
+		user:    User{Username: "alice", Passkey: "abc123"},
+	}
+	config := NewServerConfig("", "http://localhost:9999/announce", "", "templates")
+	config.Private = true
+	config.AnnounceList = []string{"udp://backup:80"}
+	server := &Server{config: config, store: store}
+
+	r := mux.NewRouter()
+	r.Handle("/torrent/{id}/download", DownloadHandler(server))
+	r.Handle("/torrent/{id}/download/{passkey}", DownloadHandler(server))
+	r.Handle("/announce/{passkey}", AnnounceHandler(server))
+
+	url := "/torrent/" + store.torrent.ID.String() + "/download"
+
+	// private mode needs a login for the passkey
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, url, nil))
+	if w.Code != http.StatusSeeOther {
+		t.Fatalf("want: %v, got %v", http.StatusSeeOther, w.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, url, nil)
+	req.AddCookie(&http.Cookie{Name: sessionCookie, Value: "session"})
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("want: %v, got %v", http.StatusOK, w.Code)
+	}
+	if cd := w.Header().Get("Content-Disposition"); cd != `attachment; filename="file _1_.iso.torrent"` {
+		t.Errorf("unexpected content disposition %s", cd)
+	}
+
+	m, err := metainfo.Parse(w.Body.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.InfoHash != sha1.Sum([]byte(info)) || !m.Private {
+		t.Errorf("info changed")
+	}
+	if m.Announce != "http://localhost:9999/announce/abc123" {
+		t.Errorf("want announce with passkey, got %s", m.Announce)
+	}
+	if len(m.AnnounceList) != 2 || m.AnnounceList[0][0] != m.Announce || m.AnnounceList[1][0] != "udp://backup:80" {
+		t.Errorf("unexpected announce list %v", m.AnnounceList)
+	}
+	if m.Comment != "hello" || !strings.Contains(w.Body.String(), "8:url-listl19:http://mirror/file/e") {
+		t.Errorf("want the other keys of the upload kept, got %s", w.Body.String())
+	}
+
+	// feed readers download with the passkey in the path
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, url+"/abc123", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("want: %v, got %v", http.StatusOK, w.Code)
+	}
+	m, err = metainfo.Parse(w.Body.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Announce != "http://localhost:9999/announce/abc123" {
+		t.Errorf("want announce with passkey, got %s", m.Announce)
+	}
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, url+"/wrong", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("want: %v, got %v", http.StatusNotFound, w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/announce/wrong?port=6881", nil))
+	if w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), "passkey is not valid") {
+		t.Errorf("want passkey failure, got %v %s", w.Code, w.Body.String())
+	}
+
+	// rejected announces are not counted in the stats
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/announce/abc123?port=x", nil))
+	if w.Code != http.StatusBadRequest || server.announces.Load() != 0 {
+		t.Errorf("want bad request not counted, got %v and %d announces", w.Code, server.announces.Load())
+	}
+}
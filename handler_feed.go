@@ -0,0 +1,254 @@
+package tracker
+
+import (
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+)
+
+// Number of torrents in a feed.
+const feedSize = 50
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string        `xml:"title"`
+	Link        string        `xml:"link"`
+	GUID        rssGUID       `xml:"guid"`
+	PubDate     string        `xml:"pubDate"`
+	Category    string        `xml:"category,omitempty"`
+	Description string        `xml:"description,omitempty"`
+	Enclosure   *rssEnclosure `xml:"enclosure"`
+}
+
+type rssGUID struct {
+	Value       string `xml:",chardata"`
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+}
+
+type rssEnclosure struct {
+	URL    string `xml:"url,attr"`
+	Length int64  `xml:"length,attr"`
+	Type   string `xml:"type,attr"`
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomEntry struct {
+	ID       string        `xml:"id"`
+	Title    string        `xml:"title"`
+	Updated  string        `xml:"updated"`
+	Links    []atomLink    `xml:"link"`
+	Category *atomCategory `xml:"category"`
+	Summary  string        `xml:"summary,omitempty"`
+}
+
+type atomLink struct {
+	Href   string `xml:"href,attr"`
+	Rel    string `xml:"rel,attr,omitempty"`
+	Type   string `xml:"type,attr,omitempty"`
+	Length int64  `xml:"length,attr,omitempty"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+// Returns the public URL of the web UI without a trailing slash,
+// or an empty string for relative links if none is known.
+func (c *ServerConfig) publicURL() string {
+	if c.PublicURL != "" {
+		return strings.TrimSuffix(c.PublicURL, "/")
+	}
+	u, err := url.Parse(c.AnnounceURL)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return ""
+	}
+	return u.Scheme + "://" + u.Host
+}
+
+// Returns the feed link of the index with the search and category of q.
+// The passkey is added when the feed would otherwise need a login.
+func (c *ServerConfig) feedURL(format string, q TorrentQuery, passkey string) string {
+	u := "/feed/" + format
+	if passkey != "" && (c.Private || c.RequireLogin) {
+		u += "/" + url.PathEscape(passkey)
+	}
+	v := url.Values{}
+	if q.Search != "" {
+		v.Set("q", q.Search)
+	}
+	if q.Category != "" {
+		v.Set("category", q.Category)
+	}
+	for _, tag := range q.Tags {
+		v.Add("tag", tag)
+	}
+	if len(v) > 0 {
+		u += "?" + v.Encode()
+	}
+	return u
+}
+
+// Serves the recently added torrents as an RSS or Atom feed, filtered like the index.
+// Feed readers cannot log in, so private trackers and trackers that require a login
+// serve feeds only with the passkey of a user, which is also used in the magnet links.
+func FeedHandler(server *Server, format string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		passkey, ok := mux.Vars(r)["passkey"]
+		if ok {
+			user, err := server.store.UserByPasskey(ctx, passkey)
+			if errors.Is(err, pgx.ErrNoRows) {
+				http.Error(w, "passkey is not valid", http.StatusNotFound)
+				return
+			}
+			if err != nil {
+				log.Ctx(ctx).Error().Err(err).Str("source", "http_feed").Msg("cant get user by passkey")
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			passkey = user.Passkey
+		} else if server.config.Private || server.config.RequireLogin {
+			http.Error(w, "feed requires a passkey", http.StatusForbidden)
+			return
+		}
+
+		// unregistered torrents cannot be announced to in private mode
+		q := TorrentQuery{Sort: "created_at", Order: "desc", Limit: feedSize, Uploaded: server.config.Private}
+		err := parseTorrentFilter(r, &q)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		torrents, _, err := server.store.ListTorrents(ctx, q)
+		if err != nil {
+			log.Ctx(ctx).Error().Err(err).Str("source", "http_feed").Msg("cant list torrents")
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		base := server.config.publicURL()
+		announce := server.config.AnnounceURL
+		if server.config.Private {
+			announce = server.config.passkeyURL(passkey)
+		}
+
+		title := "tracker"
+		if q.Category != "" {
+			title += " - " + q.Category
+		}
+		if q.Search != "" {
+			title += " - " + q.Search
+		}
+
+		// the newest torrent is the last change of the feed
+		updated := time.Unix(0, 0).UTC()
+		if len(torrents) > 0 {
+			updated = torrents[0].CreatedAt.UTC()
+		}
+
+		var feed any
+		switch format {
+		case "rss":
+			channel := rssChannel{
+				Title:         title,
+				Link:          base + "/",
+				Description:   "Recently added torrents",
+				LastBuildDate: updated.Format(time.RFC1123Z),
+			}
+			for _, t := range torrents {
+				item := rssItem{
+					Title:    t.Title(),
+					Link:     t.Magnet(announce),
+					GUID:     rssGUID{Value: base + "/torrent/" + t.ID.String(), IsPermaLink: true},
+					PubDate:  t.CreatedAt.UTC().Format(time.RFC1123Z),
+					Category: t.Category,
+				}
+				// only uploaded torrents have a .torrent file, the size of which is not known
+				if t.Uploaded {
+					item.Enclosure = &rssEnclosure{URL: server.config.downloadURL(t.ID, passkey), Type: "application/x-bittorrent"}
+				}
+				if t.Name != "" {
+					item.Description = t.SizeText()
+				}
+				channel.Items = append(channel.Items, item)
+			}
+			feed = rssFeed{Version: "2.0", Channel: channel}
+			w.Header().Set("Content-Type", "application/rss+xml; charset=utf-8")
+		case "atom":
+			atom := atomFeed{
+				ID:      base + "/feed/atom",
+				Title:   title,
+				Updated: updated.Format(time.RFC3339),
+				Links: []atomLink{
+					{Href: base + "/", Rel: "alternate", Type: "text/html"},
+				},
+			}
+			for _, t := range torrents {
+				entry := atomEntry{
+					ID:      "urn:uuid:" + t.ID.String(),
+					Title:   t.Title(),
+					Updated: t.CreatedAt.UTC().Format(time.RFC3339),
+					Links: []atomLink{
+						{Href: base + "/torrent/" + t.ID.String(), Rel: "alternate", Type: "text/html"},
+						{Href: t.Magnet(announce), Rel: "related"},
+					},
+				}
+				if t.Uploaded {
+					entry.Links = append(entry.Links, atomLink{Href: server.config.downloadURL(t.ID, passkey), Rel: "enclosure", Type: "application/x-bittorrent"})
+				}
+				if t.Category != "" {
+					entry.Category = &atomCategory{Term: t.Category}
+				}
+				if t.Name != "" {
+					entry.Summary = t.SizeText()
+				}
+				atom.Entries = append(atom.Entries, entry)
+			}
+			feed = atom
+			w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		data, err := xml.MarshalIndent(feed, "", "  ")
+		if err != nil {
+			log.Ctx(ctx).Error().Err(err).Str("source", "http_feed").Msg("cant marshal feed")
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.Write([]byte(xml.Header))
+		w.Write(data)
+	}
+}
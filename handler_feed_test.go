@@ -0,0 +1,155 @@
+package tracker
+
+import (
+	"context"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v5"
+)
+
+// Serves a fixed list of torrents and one user to the feed handlers.
+type feedStore struct {
+	TorrentStorable
+	torrents []Torrent
+	user     User
+	query    TorrentQuery
+}
+
+func (s *feedStore) ListTorrents(ctx context.Context, q TorrentQuery) ([]Torrent, int, error) {
+	s.query = q
+	var torrents []Torrent
+	for _, t := range s.torrents {
+		if !q.Uploaded || t.Uploaded {
+			torrents = append(torrents, t)
+		}
+	}
+	return torrents, len(torrents), nil
+}
+
+func (s *feedStore) UserByPasskey(ctx context.Context, passkey string) (User, error) {
+	if passkey != s.user.Passkey {
+		return User{}, pgx.ErrNoRows
+	}
+	return s.user, nil
+}
+
+func TestFeed(t *testing.T) {
+	store := &feedStore{
+		torrents: []Torrent{
+			{ID: uuid.Must(uuid.NewV4()), InfoHash: []byte("aaaaaaaaaaaaaaaaaaaa"), Name: "debian 12.iso", Size: 1 << 30, Uploaded: true, Category: "linux", CreatedAt: time.Now()},
+			{ID: uuid.Must(uuid.NewV4()), InfoHash: []byte("bbbbbbbbbbbbbbbbbbbb"), CreatedAt: time.Now().Add(-time.Hour)},
+			// named by an admin without uploaded metainfo
+			{ID: uuid.Must(uuid.NewV4()), InfoHash: []byte("cccccccccccccccccccc"), Name: "renamed", CreatedAt: time.Now().Add(-2 * time.Hour)},
+		},
+		user: User{Username: "alice", Passkey: "abc123"},
+	}
+	config := NewServerConfig("", "http://localhost:9999/announce", "", "templates")
+	server := &Server{config: config, store: store}
+
+	r := mux.NewRouter()
+	r.Handle("/feed/rss", FeedHandler(server, "rss"))
+	r.Handle("/feed/atom", FeedHandler(server, "atom"))
+	r.Handle("/feed/rss/{passkey}", FeedHandler(server, "rss"))
+	r.Handle("/feed/atom/{passkey}", FeedHandler(server, "atom"))
+
+	get := func(target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+		return w
+	}
+
+	w := get("/feed/rss?category=linux&q=debian")
+	if w.Code != http.StatusOK {
+		t.Fatalf("want: %v, got %v", http.StatusOK, w.Code)
+	}
+	if store.query.Category != "linux" || store.query.Search != "debian" || store.query.Limit != feedSize || store.query.Uploaded {
+		t.Errorf("unexpected query %+v", store.query)
+	}
+	var rss rssFeed
+	if err := xml.Unmarshal(w.Body.Bytes(), &rss); err != nil {
+		t.Fatal(err)
+	}
+	if len(rss.Channel.Items) != 3 {
+		t.Fatalf("want 3 items, got %d", len(rss.Channel.Items))
+	}
+	item := rss.Channel.Items[0]
+	if item.Title != "debian 12.iso" || item.Category != "linux" || item.Enclosure == nil || item.Enclosure.Type != "application/x-bittorrent" {
+		t.Errorf("unexpected item %+v", item)
+	}
+	want := "magnet:?xt=urn:btih:6161616161616161616161616161616161616161&dn=debian+12.iso&tr=http%3A%2F%2Flocalhost%3A9999%2Fannounce"
+	if item.Link != want {
+		t.Errorf("want magnet %s, got %s", want, item.Link)
+	}
+	// links are built from the announce URL, not the request host
+	if download := "http://localhost:9999/torrent/" + store.torrents[0].ID.String() + "/download"; item.Enclosure.URL != download {
+		t.Errorf("want enclosure %s, got %s", download, item.Enclosure.URL)
+	}
+	if item.GUID.Value != "http://localhost:9999/torrent/"+store.torrents[0].ID.String() {
+		t.Errorf("unexpected guid %s", item.GUID.Value)
+	}
+	for _, item := range rss.Channel.Items[1:] {
+		if item.Enclosure != nil {
+			t.Errorf("want no enclosure without metainfo, got %+v", item.Enclosure)
+		}
+	}
+
+	config.PublicURL = "https://tracker.example.com/"
+	if got := config.publicURL(); got != "https://tracker.example.com" {
+		t.Errorf("want configured public url, got %s", got)
+	}
+	config.PublicURL = ""
+
+	w = get("/feed/atom")
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "application/atom+xml") {
+		t.Fatalf("want atom feed, got %v %s", w.Code, w.Header().Get("Content-Type"))
+	}
+	var atom atomFeed
+	if err := xml.Unmarshal(w.Body.Bytes(), &atom); err != nil {
+		t.Fatal(err)
+	}
+	if len(atom.Entries) != 3 || atom.Entries[1].Title != "6262626262626262626262626262626262626262" {
+		t.Errorf("unexpected entries %+v", atom.Entries)
+	}
+
+	// private feeds need a passkey and only list registered torrents
+	config.Private = true
+	if w := get("/feed/rss"); w.Code != http.StatusForbidden {
+		t.Errorf("want: %v, got %v", http.StatusForbidden, w.Code)
+	}
+	if w := get("/feed/rss/nope"); w.Code != http.StatusNotFound {
+		t.Errorf("want: %v, got %v", http.StatusNotFound, w.Code)
+	}
+
+	w = get("/feed/atom/abc123")
+	if w.Code != http.StatusOK {
+		t.Fatalf("want: %v, got %v", http.StatusOK, w.Code)
+	}
+	atom = atomFeed{}
+	if err := xml.Unmarshal(w.Body.Bytes(), &atom); err != nil {
+		t.Fatal(err)
+	}
+	if !store.query.Uploaded {
+		t.Errorf("want private feeds to list only uploaded torrents, got %+v", store.query)
+	}
+	if len(atom.Entries) != 1 || atom.Entries[0].Title != "debian 12.iso" {
+		t.Fatalf("want only the uploaded torrent, got %+v", atom.Entries)
+	}
+	if magnet := atom.Entries[0].Links[1].Href; !strings.HasSuffix(magnet, "&tr=http%3A%2F%2Flocalhost%3A9999%2Fannounce%2Fabc123") {
+		t.Errorf("want passkey in magnet, got %s", magnet)
+	}
+	links := atom.Entries[0].Links
+	if len(links) != 3 || links[2].Rel != "enclosure" || links[2].Href != "http://localhost:9999/torrent/"+store.torrents[0].ID.String()+"/download/abc123" {
+		t.Errorf("want download enclosure with passkey, got %+v", links)
+	}
+
+	if got := config.feedURL("rss", TorrentQuery{Category: "linux"}, "abc123"); got != "/feed/rss/abc123?category=linux" {
+		t.Errorf("unexpected feed url %s", got)
+	}
+}
@@ -6,9 +6,28 @@ import (
 	"github.com/salimnassim/tracker/metric"
 )
 
+type liveness struct {
+	Status string `json:"status"`
+}
+
+// Replies ok as long as the process serves requests.
 func HealthHandler() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		metric.TrackerHealth.Inc()
-		w.WriteHeader(http.StatusOK)
+		replyJSON(w, liveness{Status: HealthOK}, http.StatusOK)
+	}
+}
+
+// Replies with the readiness checks, with 503 if any of them is degraded.
+func ReadinessHandler(server *Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		metric.TrackerHealth.Inc()
+		readiness := server.Readiness(r.Context())
+
+		statusCode := http.StatusOK
+		if readiness.Status != HealthOK {
+			statusCode = http.StatusServiceUnavailable
+		}
+		replyJSON(w, readiness, statusCode)
 	}
 }
@@ -0,0 +1,105 @@
+package tracker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/salimnassim/tracker/migrations"
+)
+
+// Answers readiness checks with a fixed connection error and migration version.
+type healthStore struct {
+	TorrentStorable
+	pingErr error
+	version int64
+	dirty   bool
+}
+
+func (s *healthStore) Ping(ctx context.Context) (bool, error) {
+	return s.pingErr == nil, s.pingErr
+}
+
+func (s *healthStore) MigrationVersion(ctx context.Context) (int64, bool, error) {
+	if s.pingErr != nil {
+		return 0, false, s.pingErr
+	}
+	return s.version, s.dirty, nil
+}
+
+func TestReadinessHandler(t *testing.T) {
+	latest := migrations.Latest()
+	if latest < 20261019220000 {
+		t.Fatalf("want embedded migrations, got latest %d", latest)
+	}
+
+	for _, tc := range []struct {
+		name   string
+		store  *healthStore
+		setup  func(server *Server)
+		status int
+	}{
+		{"ready", &healthStore{version: latest}, nil, http.StatusOK},
+		{"database down", &healthStore{pingErr: errors.New("dial tcp db.internal:5432: connection refused")}, nil, http.StatusServiceUnavailable},
+		{"migration behind", &healthStore{version: latest - 1}, nil, http.StatusServiceUnavailable},
+		{"migration dirty", &healthStore{version: latest, dirty: true}, nil, http.StatusServiceUnavailable},
+		{"migration ahead", &healthStore{version: latest + 1}, nil, http.StatusOK},
+		{"stale task", &healthStore{version: latest}, func(server *Server) {
+			server.tasks.add("stale", time.Minute, time.Now().Add(-3*time.Minute))
+		}, http.StatusServiceUnavailable},
+		{"queue full", &healthStore{version: latest}, func(server *Server) {
+			server.writeBehind = NewWriteBehindStore(&recordingStore{}, WriteBehindConfig{FlushInterval: time.Hour, FlushSize: 10, QueueSize: 1})
+			server.writeBehind.Log(context.Background(), AnnounceRequest{IP: "127.0.0.1"})
+		}, http.StatusServiceUnavailable},
+	} {
+		server := &Server{config: NewServerConfig("", "", "", "templates"), store: tc.store}
+		server.tasks.add("fresh", time.Minute, time.Now())
+		if tc.setup != nil {
+			tc.setup(server)
+		}
+
+		w := httptest.NewRecorder()
+		ReadinessHandler(server)(w, httptest.NewRequest(http.MethodGet, "/health/ready", nil))
+		if w.Code != tc.status {
+			t.Errorf("%s: want %d, got %d: %s", tc.name, tc.status, w.Code, w.Body)
+		}
+
+		var readiness Readiness
+		err := json.Unmarshal(w.Body.Bytes(), &readiness)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (readiness.Status == HealthOK) != (tc.status == http.StatusOK) {
+			t.Errorf("%s: unexpected status %q", tc.name, readiness.Status)
+		}
+		if readiness.Migrations.Latest != latest || len(readiness.Tasks) == 0 || readiness.Tasks[0].Name != "fresh" {
+			t.Errorf("%s: unexpected breakdown %+v", tc.name, readiness)
+		}
+		if strings.Contains(w.Body.String(), "db.internal") {
+			t.Errorf("%s: want no driver errors in the response, got %s", tc.name, w.Body)
+		}
+	}
+
+	// failed runs are not heartbeats
+	server := &Server{store: &healthStore{}}
+	server.tasks.add("task", time.Minute, time.Now().Add(-time.Hour))
+	server.runTask("task", func(ts TorrentStorable) error { return errors.New("failed") })
+	if server.tasks.tasks["task"].ran {
+		t.Errorf("want no heartbeat after a failed run")
+	}
+	server.runTask("task", func(ts TorrentStorable) error { return nil })
+	if hb := server.tasks.tasks["task"]; !hb.ran || time.Since(hb.lastRun) > time.Minute {
+		t.Errorf("want a heartbeat after a run, got %+v", hb)
+	}
+
+	w := httptest.NewRecorder()
+	HealthHandler()(w, httptest.NewRequest(http.MethodGet, "/health/live", nil))
+	if w.Code != http.StatusOK || w.Body.String() != `{"status":"ok"}` {
+		t.Errorf("want live, got %d %s", w.Code, w.Body)
+	}
+}
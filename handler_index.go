@@ -1,12 +1,18 @@
 package tracker
 
 import (
+	"errors"
+	"fmt"
 	"html/template"
 	"net/http"
+	"net/url"
 	"path/filepath"
+	"slices"
+	"time"
 
 	"github.com/gofrs/uuid"
 	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v5"
 	"github.com/rs/zerolog/log"
 )
 
@@ -15,16 +21,61 @@ func IndexHandler(server *Server) http.HandlerFunc {
 		ctx := r.Context()
 		w.Header().Set("Content-Type", "text/html; charset=utf-8")
 
-		torrents, err := server.store.Torrents(ctx)
+		q, err := parseIndexQuery(r)
 		if err != nil {
-			log.Error().Err(err).Msg("cant get torrents in index")
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// one extra row tells if there is another page
+		q.Limit = indexPageSize + 1
+		torrents, _, err := server.store.ListTorrents(ctx, q)
+		if err != nil {
+			log.Ctx(ctx).Error().Err(err).Msg("cant get torrents in index")
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		var prev, next string
+		more := len(torrents) > indexPageSize
+		if q.Before != nil {
+			if more {
+				torrents = torrents[1:]
+			}
+		} else if more {
+			torrents = torrents[:indexPageSize]
+		}
+		if len(torrents) > 0 {
+			first := NewTorrentCursor(q.Sort, torrents[0])
+			last := NewTorrentCursor(q.Sort, torrents[len(torrents)-1])
+			if q.After != nil || (q.Before != nil && more) {
+				prev = indexURL(q, q.Sort, q.Order, "before", first)
+			}
+			if q.Before != nil || more {
+				next = indexURL(q, q.Sort, q.Order, "after", last)
+			}
+		}
+
+		// clicking the current sort column flips the order
+		sorts := map[string]string{}
+		for _, sort := range torrentSorts {
+			order := "desc"
+			if sort == q.Sort && q.Order == "desc" {
+				order = "asc"
+			}
+			sorts[sort] = indexURL(q, sort, order, "", nil)
+		}
+
+		categories, err := server.store.Categories(ctx)
+		if err != nil {
+			log.Ctx(ctx).Error().Err(err).Msg("cant get categories in index")
 			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
 
 		tmpl, err := template.ParseFiles(filepath.Join(server.config.TemplatePath, "index.html"))
 		if err != nil {
-			log.Error().Err(err).Msg("cant parse template in index")
+			log.Ctx(ctx).Error().Err(err).Msg("cant parse template in index")
 			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
@@ -32,18 +83,101 @@ func IndexHandler(server *Server) http.HandlerFunc {
 		// todo: make a struct for view
 		dto := map[string]interface{}{
 			"Torrents":    torrents,
+			"Categories":  categories,
+			"Query":       q,
+			"Sorts":       sorts,
+			"Prev":        prev,
+			"Next":        next,
 			"AnnounceURL": server.config.AnnounceURL,
 		}
 
+		user, err := server.headerView(w, r, dto)
+		if err != nil {
+			log.Ctx(ctx).Error().Err(err).Msg("cant get session in index")
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		// magnet links of private trackers need the passkey
+		if server.config.Private && user != nil {
+			dto["AnnounceURL"] = server.config.passkeyURL(user.Passkey)
+		}
+		var passkey string
+		if user != nil {
+			passkey = user.Passkey
+		}
+		dto["RSSURL"] = server.config.feedURL("rss", q, passkey)
+		dto["AtomURL"] = server.config.feedURL("atom", q, passkey)
+
 		err = tmpl.Execute(w, dto)
 		if err != nil {
-			log.Error().Err(err).Msg("cant execute template in index")
+			log.Ctx(ctx).Error().Err(err).Msg("cant execute template in index")
 			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
 	}
 }
 
+// Number of torrents on an index page.
+const indexPageSize = 50
+
+// Parses the filter, sort and cursor of the index page.
+func parseIndexQuery(r *http.Request) (TorrentQuery, error) {
+	query := r.URL.Query()
+	q := TorrentQuery{Sort: "created_at", Order: "desc"}
+
+	if v := query.Get("sort"); v != "" {
+		if !slices.Contains(torrentSorts, v) {
+			return q, errors.New("sort is not valid")
+		}
+		q.Sort = v
+	}
+	if v := query.Get("order"); v != "" {
+		if v != "asc" && v != "desc" {
+			return q, errors.New("order is not valid")
+		}
+		q.Order = v
+	}
+
+	var err error
+	if v := query.Get("after"); v != "" {
+		q.After, err = ParseTorrentCursor(v)
+		if err != nil {
+			return q, errors.New("after is not valid")
+		}
+	}
+	if v := query.Get("before"); v != "" {
+		if q.After != nil {
+			return q, errors.New("after and before are exclusive")
+		}
+		q.Before, err = ParseTorrentCursor(v)
+		if err != nil {
+			return q, errors.New("before is not valid")
+		}
+	}
+
+	return q, parseTorrentFilter(r, &q)
+}
+
+// Builds an index link with the filter of q, the sort and an optional cursor.
+func indexURL(q TorrentQuery, sort string, order string, direction string, cursor *TorrentCursor) string {
+	v := url.Values{}
+	if q.Search != "" {
+		v.Set("q", q.Search)
+	}
+	if q.Category != "" {
+		v.Set("category", q.Category)
+	}
+	for _, tag := range q.Tags {
+		v.Add("tag", tag)
+	}
+	v.Set("sort", sort)
+	v.Set("order", order)
+	if cursor != nil {
+		v.Set(direction, cursor.String())
+	}
+	return "/?" + v.Encode()
+}
+
 func TorrentHandler(server *Server) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
@@ -53,33 +187,133 @@ func TorrentHandler(server *Server) http.HandlerFunc {
 
 		uuid, err := uuid.FromString(vars["id"])
 		if err != nil {
-			log.Error().Err(err).Msg("cant create uuid from string in torrent")
+			log.Ctx(ctx).Error().Err(err).Msg("cant create uuid from string in torrent")
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		torrent, err := server.store.TorrentByID(ctx, uuid)
+		if errors.Is(err, pgx.ErrNoRows) || (err == nil && torrent.HasFlag(TorrentHidden)) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Ctx(ctx).Error().Err(err).Msg("cant get torrent in torrent")
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		type fileView struct {
+			Path string
+			Size string
+		}
+		var files []fileView
+		meta, err := server.store.MetaInfo(ctx, uuid)
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			log.Ctx(ctx).Error().Err(err).Msg("cant get metainfo in torrent")
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		for _, f := range meta.Files {
+			files = append(files, fileView{Path: f.Path, Size: FormatSize(f.Length)})
+		}
+
+		page, limit, err := parsePage(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		peers, err := server.store.ListPeers(ctx, uuid, limit, (page-1)*limit)
+		if err != nil {
+			log.Ctx(ctx).Error().Err(err).Msg("cant get peers in torrent")
 			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
 
-		peers, err := server.store.Peers(ctx, uuid)
+		type peerView struct {
+			Client     string
+			Version    string
+			Uploaded   string
+			Downloaded string
+			Left       string
+			Event      string
+			UpdatedAt  time.Time
+		}
+		var peerViews []peerView
+		for _, p := range peers {
+			name, version := ParseClient(p.PeerID)
+			peerViews = append(peerViews, peerView{
+				Client:     name,
+				Version:    version,
+				Uploaded:   FormatSize(int64(p.Uploaded)),
+				Downloaded: FormatSize(int64(p.Downloaded)),
+				Left:       FormatSize(int64(p.Left)),
+				Event:      p.Event,
+				UpdatedAt:  p.UpdatedAt,
+			})
+		}
+
+		clients, err := server.store.PeerClients(ctx, uuid)
 		if err != nil {
-			log.Error().Err(err).Msg("cant get peers in torrent")
+			log.Ctx(ctx).Error().Err(err).Msg("cant get clients in torrent")
 			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
 
+		now := time.Now()
+		from := now.Add(-7 * 24 * time.Hour)
+		history, err := server.store.SwarmHistory(ctx, torrent.ID, SnapshotHour, from)
+		if err != nil {
+			log.Ctx(ctx).Error().Err(err).Msg("cant get swarm history in torrent")
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		daily, err := server.store.SwarmActivity(ctx, torrent.InfoHash, now.AddDate(0, 0, -30), "day")
+		if err != nil {
+			log.Ctx(ctx).Error().Err(err).Msg("cant get daily activity in torrent")
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		var prev, next string
+		if page > 1 {
+			prev = fmt.Sprintf("?page=%d&limit=%d", page-1, limit)
+		}
+		if page*limit < torrent.Seeders+torrent.Leechers {
+			next = fmt.Sprintf("?page=%d&limit=%d", page+1, limit)
+		}
+
 		tmpl, err := template.ParseFiles(filepath.Join(server.config.TemplatePath, "torrent.html"))
 		if err != nil {
-			log.Error().Err(err).Msg("cant parse template in torrent")
+			log.Ctx(ctx).Error().Err(err).Msg("cant parse template in torrent")
 			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
 
 		// todo: make a struct for view
 		dto := map[string]interface{}{
-			"Peers": peers,
+			"Torrent":   torrent,
+			"Meta":      meta,
+			"Files":     files,
+			"Peers":     peerViews,
+			"PeerCount": torrent.Seeders + torrent.Leechers,
+			"Clients":   clients,
+			"Swarm":     newSwarmChart(history, from, now, 600, 120),
+			"Completed": newCompletedChart(daily, now, 30, 600, 60),
+			"Prev":      prev,
+			"Next":      next,
+		}
+
+		_, err = server.headerView(w, r, dto)
+		if err != nil {
+			log.Ctx(ctx).Error().Err(err).Msg("cant get session in torrent")
+			w.WriteHeader(http.StatusInternalServerError)
+			return
 		}
 
 		err = tmpl.Execute(w, dto)
 		if err != nil {
-			log.Error().Err(err).Msg("cant execute template in torrent")
+			log.Ctx(ctx).Error().Err(err).Msg("cant execute template in torrent")
 			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
@@ -0,0 +1,259 @@
+package tracker
+
+import (
+	"bytes"
+	"context"
+	"html"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v5"
+)
+
+// Pages torrents in memory the way ListTorrents does.
+type indexStore struct {
+	TorrentStorable
+	torrents []Torrent
+}
+
+func (s *indexStore) ListTorrents(ctx context.Context, q TorrentQuery) ([]Torrent, int, error) {
+	order, cursor := q.Order, q.After
+	if q.Before != nil {
+		cursor = q.Before
+		order = map[string]string{"asc": "desc", "desc": "asc"}[q.Order]
+	}
+	compare := func(a, b *TorrentCursor) int {
+		if a.Value != b.Value {
+			return int(a.Value - b.Value)
+		}
+		return bytes.Compare(a.ID.Bytes(), b.ID.Bytes())
+	}
+
+	torrents := slices.Clone(s.torrents)
+	slices.SortFunc(torrents, func(a, b Torrent) int {
+		c := compare(NewTorrentCursor(q.Sort, a), NewTorrentCursor(q.Sort, b))
+		if order == "desc" {
+			return -c
+		}
+		return c
+	})
+	if cursor != nil {
+		torrents = slices.DeleteFunc(torrents, func(t Torrent) bool {
+			c := compare(NewTorrentCursor(q.Sort, t), cursor)
+			return (order == "desc" && c >= 0) || (order == "asc" && c <= 0)
+		})
+	}
+	if q.Limit > 0 && len(torrents) > q.Limit {
+		torrents = torrents[:q.Limit]
+	}
+	if q.Before != nil {
+		slices.Reverse(torrents)
+	}
+	return torrents, len(s.torrents), nil
+}
+
+func (s *indexStore) Categories(ctx context.Context) ([]Category, error) {
+	return nil, nil
+}
+
+func TestIndexPages(t *testing.T) {
+	store := &indexStore{}
+	now := time.Now().Truncate(time.Microsecond)
+	for i := 0; i < indexPageSize*2+10; i++ {
+		store.torrents = append(store.torrents, Torrent{
+			ID:        uuid.Must(uuid.NewV4()),
+			InfoHash:  []byte("aaaaaaaaaaaaaaaaaaaa"),
+			CreatedAt: now.Add(-time.Duration(i/3) * time.Minute),
+			Seeders:   i % 7,
+		})
+	}
+	server := &Server{config: NewServerConfig("", "", "", "templates"), store: store}
+
+	rowRe := regexp.MustCompile(`href="/torrent/([0-9a-f-]{36})"`)
+	nextRe := regexp.MustCompile(`<a href="([^"]*)">next`)
+	prevRe := regexp.MustCompile(`<a href="([^"]*)">&larr; previous`)
+
+	get := func(target string) (ids []string, prev string, next string) {
+		t.Helper()
+		w := httptest.NewRecorder()
+		IndexHandler(server)(w, httptest.NewRequest(http.MethodGet, target, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: want 200, got %d", target, w.Code)
+		}
+		body, _ := io.ReadAll(w.Body)
+		for _, m := range rowRe.FindAllSubmatch(body, -1) {
+			ids = append(ids, string(m[1]))
+		}
+		if m := prevRe.FindSubmatch(body); m != nil {
+			prev = html.UnescapeString(string(m[1]))
+		}
+		if m := nextRe.FindSubmatch(body); m != nil {
+			next = html.UnescapeString(string(m[1]))
+		}
+		return ids, prev, next
+	}
+
+	for _, sort := range []string{"created_at", "seeders"} {
+		var pages [][]string
+		target := "/?sort=" + sort + "&order=desc"
+		for target != "" {
+			ids, prev, next := get(target)
+			if (prev == "") != (len(pages) == 0) {
+				t.Errorf("%s: page %d has previous link %q", sort, len(pages), prev)
+			}
+			pages = append(pages, ids)
+			target = next
+		}
+
+		var seen []string
+		for _, page := range pages {
+			seen = append(seen, page...)
+		}
+		slices.Sort(seen)
+		if len(pages) != 3 || len(seen) != len(store.torrents) || len(slices.Compact(seen)) != len(store.torrents) {
+			t.Fatalf("%s: want every torrent once over 3 pages, got %d pages with %d rows", sort, len(pages), len(seen))
+		}
+
+		// walk back from the last page
+		target = "/?sort=" + sort + "&order=desc"
+		_, _, next := get(target)
+		_, _, next = get(next)
+		_, prev, _ := get(next)
+		_, prev, _ = get(prev)
+		ids, prev, _ := get(prev)
+		if prev != "" || !slices.Equal(ids, pages[0]) {
+			t.Errorf("%s: previous links do not lead back to the first page", sort)
+		}
+	}
+
+	for _, query := range []string{"sort=name", "order=up", "after=x", "after=" + NewTorrentCursor("seeders", store.torrents[0]).String() + "&before=" + NewTorrentCursor("seeders", store.torrents[0]).String()} {
+		w := httptest.NewRecorder()
+		IndexHandler(server)(w, httptest.NewRequest(http.MethodGet, "/?"+query, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: want 400, got %d", query, w.Code)
+		}
+	}
+}
+
+func TestTorrentCursor(t *testing.T) {
+	c := &TorrentCursor{Value: -1700000000000000, ID: uuid.Must(uuid.NewV4())}
+	got, err := ParseTorrentCursor(c.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *got != *c {
+		t.Errorf("want %+v, got %+v", c, got)
+	}
+
+	for _, s := range []string{"", "!!", "MTI", "MTI6eA"} {
+		if _, err := ParseTorrentCursor(s); err == nil {
+			t.Errorf("%q: want error", s)
+		}
+	}
+}
+
+// Serves one torrent with peers and activity to the torrent page.
+type torrentPageStore struct {
+	TorrentStorable
+	torrent Torrent
+	peers   []Peer
+	steps   []string
+}
+
+func (s *torrentPageStore) TorrentByID(ctx context.Context, torrentID uuid.UUID) (Torrent, error) {
+	if torrentID != s.torrent.ID {
+		return Torrent{}, pgx.ErrNoRows
+	}
+	return s.torrent, nil
+}
+
+func (s *torrentPageStore) MetaInfo(ctx context.Context, torrentID uuid.UUID) (TorrentMetaInfo, error) {
+	return TorrentMetaInfo{}, pgx.ErrNoRows
+}
+
+func (s *torrentPageStore) ListPeers(ctx context.Context, torrentID uuid.UUID, limit int, offset int) ([]Peer, error) {
+	return s.peers[min(offset, len(s.peers)):min(offset+limit, len(s.peers))], nil
+}
+
+func (s *torrentPageStore) PeerClients(ctx context.Context, torrentID uuid.UUID) ([]ClientCount, error) {
+	return []ClientCount{{Name: "qBittorrent", Version: "4.6.2", Peers: len(s.peers)}}, nil
+}
+
+func (s *torrentPageStore) SwarmActivity(ctx context.Context, infoHash []byte, from time.Time, step string) ([]SwarmActivity, error) {
+	s.steps = append(s.steps, step)
+	at := time.Now().UTC().Truncate(24 * time.Hour)
+	return []SwarmActivity{
+		{At: at.AddDate(0, 0, -2), Seeders: 1, Leechers: 4, Completed: 1},
+		{At: at, Seeders: 3, Leechers: 2, Completed: 2},
+	}, nil
+}
+
+func (s *torrentPageStore) SwarmHistory(ctx context.Context, torrentID uuid.UUID, resolution string, from time.Time) ([]SwarmSnapshot, error) {
+	s.steps = append(s.steps, resolution)
+	at := time.Now().UTC().Truncate(time.Hour)
+	return []SwarmSnapshot{
+		{At: at.Add(-2 * time.Hour), Seeders: 1, Leechers: 4},
+		{At: at.Add(-time.Hour), Seeders: 3, Leechers: 2},
+	}, nil
+}
+
+func TestTorrentPage(t *testing.T) {
+	store := &torrentPageStore{
+		torrent: Torrent{ID: uuid.Must(uuid.NewV4()), InfoHash: []byte("aaaaaaaaaaaaaaaaaaaa"), Seeders: 30, Leechers: 30},
+	}
+	for i := 0; i < 60; i++ {
+		store.peers = append(store.peers, Peer{ID: uuid.Must(uuid.NewV4()), PeerID: []byte("-qB4620-abcdefghijkl"), Left: i % 2})
+	}
+	server := &Server{config: NewServerConfig("", "", "", "templates"), store: store}
+
+	r := mux.NewRouter()
+	r.Handle("/torrent/{id}", TorrentHandler(server))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/torrent/"+store.torrent.ID.String(), nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("want: %v, got %v", http.StatusOK, w.Code)
+	}
+	body := w.Body.String()
+	for _, want := range []string{"60 peers", "qBittorrent 4.6.2", `href="?page=2&amp;limit=50"`, `<polyline class="seeders" points="`, "<title>", "<td>4.6.2</td>"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("want %q in page", want)
+		}
+	}
+	if strings.Count(body, "qBittorrent 4.6.2") != 50 {
+		t.Errorf("want one page of peers, got %d", strings.Count(body, "qBittorrent 4.6.2"))
+	}
+	if !slices.Equal(store.steps, []string{"hour", "day"}) {
+		t.Errorf("unexpected activity steps %v", store.steps)
+	}
+}
+
+func TestCompletedChart(t *testing.T) {
+	to := time.Date(2026, 10, 19, 15, 0, 0, 0, time.UTC)
+	activity := []SwarmActivity{
+		{At: time.Date(2026, 10, 17, 0, 0, 0, 0, time.UTC), Completed: 4},
+		{At: time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC), Completed: 2},
+	}
+	chart := newCompletedChart(activity, to, 3, 300, 100)
+	if chart.Max != 4 || len(chart.Bars) != 3 {
+		t.Fatalf("unexpected chart %+v", chart)
+	}
+	for i, want := range []struct {
+		day       string
+		completed int
+		height    float64
+	}{{"2026-10-17", 4, 100}, {"2026-10-18", 0, 0}, {"2026-10-19", 2, 50}} {
+		bar := chart.Bars[i]
+		if bar.Day != want.day || bar.Completed != want.completed || bar.Height != want.height {
+			t.Errorf("bar %d: want %+v, got %+v", i, want, bar)
+		}
+	}
+}
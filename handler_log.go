@@ -0,0 +1,207 @@
+package tracker
+
+import (
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Announce log entry as shown on the log page and exports.
+type announceLogView struct {
+	CreatedAt  time.Time `json:"created_at"`
+	InfoHash   string    `json:"info_hash"`
+	PeerID     string    `json:"peer_id"`
+	PeerIDText string    `json:"peer_id_text"`
+	Client     string    `json:"client"`
+	Event      string    `json:"event"`
+	IP         string    `json:"ip"`
+	Port       int       `json:"port"`
+	Uploaded   int       `json:"uploaded"`
+	Downloaded int       `json:"downloaded"`
+	Left       int       `json:"left"`
+	RequestID  string    `json:"request_id"`
+}
+
+func newAnnounceLogView(l AnnounceLog) announceLogView {
+	text := strconv.QuoteToASCII(string(l.PeerID))
+	return announceLogView{
+		CreatedAt:  l.CreatedAt,
+		InfoHash:   hex.EncodeToString(l.InfoHash),
+		PeerID:     hex.EncodeToString(l.PeerID),
+		PeerIDText: text[1 : len(text)-1],
+		Client:     l.Client(),
+		Event:      l.Event,
+		IP:         l.IP,
+		Port:       l.Port,
+		Uploaded:   l.Uploaded,
+		Downloaded: l.Downloaded,
+		Left:       l.Left,
+		RequestID:  l.RequestID,
+	}
+}
+
+// Parses a time from a query string as RFC 3339 or as datetime-local in UTC,
+// the format of <input type="datetime-local">.
+func parseQueryTime(v string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339, v)
+	if err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02T15:04", v)
+}
+
+// Parses announce log filter from query string.
+// info_hash is hex, peer_id is hex or the raw peer id,
+// from and to are RFC 3339 or datetime-local in UTC.
+func parseAnnounceLogFilter(r *http.Request) (AnnounceLogFilter, error) {
+	query := r.URL.Query()
+	filter := AnnounceLogFilter{Limit: 100}
+
+	if v := query.Get("info_hash"); v != "" {
+		infoHash, err := hex.DecodeString(v)
+		if err != nil || len(infoHash) != 20 {
+			return filter, fmt.Errorf("info_hash is not valid")
+		}
+		filter.InfoHash = infoHash
+	}
+
+	if v := query.Get("peer_id"); v != "" {
+		filter.PeerID = []byte(v)
+		if len(v) == 40 {
+			peerID, err := hex.DecodeString(v)
+			if err == nil {
+				filter.PeerID = peerID
+			}
+		}
+	}
+
+	if v := query.Get("ip"); v != "" {
+		if net.ParseIP(v) == nil {
+			return filter, fmt.Errorf("ip is not valid")
+		}
+		filter.IP = v
+	}
+
+	if v := query.Get("from"); v != "" {
+		from, err := parseQueryTime(v)
+		if err != nil {
+			return filter, fmt.Errorf("from is not valid")
+		}
+		filter.From = from
+	}
+	if v := query.Get("to"); v != "" {
+		to, err := parseQueryTime(v)
+		if err != nil {
+			return filter, fmt.Errorf("to is not valid")
+		}
+		filter.To = to
+	}
+
+	if v := query.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > 10000 {
+			return filter, fmt.Errorf("limit is not valid")
+		}
+		filter.Limit = limit
+	}
+
+	return filter, nil
+}
+
+// Searches the announce log. Renders a page by default,
+// format=csv and format=jsonl export the result.
+func AnnounceLogHandler(server *Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		format := r.URL.Query().Get("format")
+
+		filter, err := parseAnnounceLogFilter(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		logs, err := server.store.AnnounceLogs(ctx, filter)
+		if err != nil {
+			log.Ctx(ctx).Error().Err(err).Msg("cant get announce logs in log")
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		views := make([]announceLogView, 0, len(logs))
+		for _, l := range logs {
+			views = append(views, newAnnounceLogView(l))
+		}
+
+		switch format {
+		case "csv":
+			w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+			w.Header().Set("Content-Disposition", `attachment; filename="announce_log.csv"`)
+
+			cw := csv.NewWriter(w)
+			cw.Write([]string{"created_at", "info_hash", "peer_id", "peer_id_text", "client", "event", "ip", "port", "uploaded", "downloaded", "left", "request_id"})
+			for _, v := range views {
+				cw.Write([]string{
+					v.CreatedAt.Format(time.RFC3339Nano), v.InfoHash, v.PeerID, v.PeerIDText, v.Client, v.Event, v.IP,
+					strconv.Itoa(v.Port), strconv.Itoa(v.Uploaded), strconv.Itoa(v.Downloaded), strconv.Itoa(v.Left), v.RequestID,
+				})
+			}
+			cw.Flush()
+			if cw.Error() != nil {
+				log.Ctx(ctx).Error().Err(cw.Error()).Msg("cant write csv in log")
+			}
+		case "jsonl":
+			w.Header().Set("Content-Type", "application/jsonl; charset=utf-8")
+			w.Header().Set("Content-Disposition", `attachment; filename="announce_log.jsonl"`)
+
+			enc := json.NewEncoder(w)
+			for _, v := range views {
+				err := enc.Encode(v)
+				if err != nil {
+					log.Ctx(ctx).Error().Err(err).Msg("cant write jsonl in log")
+					return
+				}
+			}
+		case "":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+
+			tmpl, err := template.ParseFiles(filepath.Join(server.config.TemplatePath, "log.html"))
+			if err != nil {
+				log.Ctx(ctx).Error().Err(err).Msg("cant parse template in log")
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+
+			query := r.URL.Query()
+			export := func(format string) string {
+				q := r.URL.Query()
+				q.Set("format", format)
+				return "/log?" + q.Encode()
+			}
+			dto := map[string]interface{}{
+				"Logs":        views,
+				"Query":       query,
+				"ExportCSV":   export("csv"),
+				"ExportJSONL": export("jsonl"),
+			}
+
+			err = tmpl.Execute(w, dto)
+			if err != nil {
+				log.Ctx(ctx).Error().Err(err).Msg("cant execute template in log")
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+		default:
+			http.Error(w, "format is not valid", http.StatusBadRequest)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package tracker
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Returns fixed announce logs and records the filter.
+type logStore struct {
+	TorrentStorable
+	filter AnnounceLogFilter
+	logs   []AnnounceLog
+}
+
+func (s *logStore) AnnounceLogs(ctx context.Context, filter AnnounceLogFilter) ([]AnnounceLog, error) {
+	s.filter = filter
+	return s.logs, nil
+}
+
+func TestAnnounceLogFilter(t *testing.T) {
+	infoHash := strings.Repeat("ab", 20)
+	peerID := []byte("-qB4620-abcdefghijkl")
+
+	for _, tc := range []struct {
+		query string
+		err   bool
+		check func(f AnnounceLogFilter) bool
+	}{
+		{"", false, func(f AnnounceLogFilter) bool { return f.Limit == 100 && f.InfoHash == nil && f.IP == "" }},
+		{"info_hash=" + infoHash, false, func(f AnnounceLogFilter) bool { return hex.EncodeToString(f.InfoHash) == infoHash }},
+		{"info_hash=abcd", true, nil},
+		{"info_hash=" + strings.Repeat("zz", 20), true, nil},
+		{"peer_id=" + hex.EncodeToString(peerID), false, func(f AnnounceLogFilter) bool { return bytes.Equal(f.PeerID, peerID) }},
+		{"peer_id=-qB4620-", false, func(f AnnounceLogFilter) bool { return string(f.PeerID) == "-qB4620-" }},
+		{"ip=10.0.0.1", false, func(f AnnounceLogFilter) bool { return f.IP == "10.0.0.1" }},
+		{"ip=2001:db8::1", false, func(f AnnounceLogFilter) bool { return f.IP == "2001:db8::1" }},
+		{"ip=10.0.0", true, nil},
+		{"from=2026-10-19T12:00:00Z&to=2026-10-19T13:00", false, func(f AnnounceLogFilter) bool {
+			return f.From.Equal(time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)) && f.To.Equal(time.Date(2026, 10, 19, 13, 0, 0, 0, time.UTC))
+		}},
+		{"from=yesterday", true, nil},
+		{"to=2026-10-19", true, nil},
+		{"limit=500", false, func(f AnnounceLogFilter) bool { return f.Limit == 500 }},
+		{"limit=0", true, nil},
+		{"limit=10001", true, nil},
+	} {
+		filter, err := parseAnnounceLogFilter(httptest.NewRequest(http.MethodGet, "/log?"+tc.query, nil))
+		if (err != nil) != tc.err {
+			t.Errorf("%q: want error %v, got %v", tc.query, tc.err, err)
+			continue
+		}
+		if tc.check != nil && !tc.check(filter) {
+			t.Errorf("%q: unexpected filter %+v", tc.query, filter)
+		}
+	}
+}
+
+func TestAnnounceLogHandler(t *testing.T) {
+	store := &logStore{logs: []AnnounceLog{{
+		AnnounceRequest: AnnounceRequest{
+			InfoHash: bytes.Repeat([]byte{0xab}, 20),
+			PeerID:   []byte("-qB4620-abcdefghijkl"),
+			Event:    "started",
+			IP:       "10.0.0.1",
+			Port:     6881,
+			Left:     100,
+		},
+		RequestID: "req-1",
+		CreatedAt: time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC),
+	}}}
+	server := &Server{config: NewServerConfig("", "", "", "templates"), store: store}
+
+	get := func(query string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		AnnounceLogHandler(server)(w, httptest.NewRequest(http.MethodGet, "/log?"+query, nil))
+		return w
+	}
+
+	w := get("ip=10.0.0.1")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "qBittorrent") || !strings.Contains(w.Body.String(), "format=csv") {
+		t.Errorf("want log page, got %d", w.Code)
+	}
+	if store.filter.IP != "10.0.0.1" {
+		t.Errorf("want filter passed to the store, got %+v", store.filter)
+	}
+
+	w = get("format=csv")
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "text/csv; charset=utf-8" {
+		t.Fatalf("want csv, got %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+	records, err := csv.NewReader(w.Body).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[0][0] != "created_at" || len(records[1]) != len(records[0]) {
+		t.Fatalf("want header and one row, got %v", records)
+	}
+	row := map[string]string{}
+	for i, name := range records[0] {
+		row[name] = records[1][i]
+	}
+	for k, want := range map[string]string{
+		"created_at":   "2026-10-19T12:00:00Z",
+		"info_hash":    strings.Repeat("ab", 20),
+		"peer_id_text": "-qB4620-abcdefghijkl",
+		"client":       "qBittorrent",
+		"ip":           "10.0.0.1",
+		"port":         "6881",
+		"left":         "100",
+		"request_id":   "req-1",
+	} {
+		if row[k] != want {
+			t.Errorf("csv %s: want %q, got %q", k, want, row[k])
+		}
+	}
+
+	w = get("format=jsonl")
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/jsonl; charset=utf-8" {
+		t.Fatalf("want jsonl, got %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+	lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	var view announceLogView
+	if len(lines) != 1 || json.Unmarshal([]byte(lines[0]), &view) != nil {
+		t.Fatalf("want one json line, got %q", w.Body.String())
+	}
+	if view.Event != "started" || view.Port != 6881 || view.RequestID != "req-1" {
+		t.Errorf("unexpected jsonl entry %+v", view)
+	}
+
+	for _, query := range []string{"format=xml", "ip=nope", "info_hash=00"} {
+		if w := get(query); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: want 400, got %d", query, w.Code)
+		}
+	}
+}
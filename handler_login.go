@@ -0,0 +1,183 @@
+package tracker
+
+import (
+	"errors"
+	"html/template"
+	"net/http"
+	"path/filepath"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+)
+
+// Adds the session user and CSRF token used by the page header to dto.
+// Returns the session user, nil if not logged in.
+func (sv *Server) headerView(w http.ResponseWriter, r *http.Request, dto map[string]interface{}) (*User, error) {
+	user, ok, err := sv.sessionUser(r)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := sv.csrfToken(w, r)
+	if err != nil {
+		return nil, err
+	}
+	dto["CSRFToken"] = token
+
+	if !ok {
+		return nil, nil
+	}
+	dto["User"] = user.Username
+	return &user, nil
+}
+
+func renderLogin(server *Server, w http.ResponseWriter, r *http.Request, message string, statusCode int) {
+	tmpl, err := template.ParseFiles(filepath.Join(server.config.TemplatePath, "login.html"))
+	if err != nil {
+		log.Ctx(r.Context()).Error().Err(err).Str("source", "http_login").Msg("cant parse template in login")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	token, err := server.csrfToken(w, r)
+	if err != nil {
+		log.Ctx(r.Context()).Error().Err(err).Str("source", "http_login").Msg("cant create csrf token")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	dto := map[string]interface{}{
+		"CSRFToken": token,
+		"Next":      safeRedirect(r.FormValue("next")),
+		"Username":  r.PostFormValue("username"),
+		"Message":   message,
+		"OIDC":      server.oidc != nil,
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(statusCode)
+	err = tmpl.Execute(w, dto)
+	if err != nil {
+		log.Ctx(r.Context()).Error().Err(err).Str("source", "http_login").Msg("cant execute template in login")
+		return
+	}
+}
+
+// Shows the login form on GET and signs in on POST.
+func LoginHandler(server *Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			renderLogin(server, w, r, "", http.StatusOK)
+			return
+		}
+
+		if !validCSRF(r) {
+			renderLogin(server, w, r, "form expired, try again", http.StatusForbidden)
+			return
+		}
+
+		var found *User
+		user, err := server.store.UserByUsername(r.Context(), r.PostFormValue("username"))
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			log.Ctx(r.Context()).Error().Err(err).Str("source", "http_login").Msg("cant get user")
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if err == nil {
+			found = &user
+		}
+
+		if !found.CheckPassword(r.PostFormValue("password")) {
+			renderLogin(server, w, r, "invalid username or password", http.StatusUnauthorized)
+			return
+		}
+
+		err = server.startSession(w, r, user)
+		if err != nil {
+			log.Ctx(r.Context()).Error().Err(err).Str("source", "http_login").Msg("cant start session")
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		http.Redirect(w, r, safeRedirect(r.PostFormValue("next")), http.StatusSeeOther)
+	}
+}
+
+func LogoutHandler(server *Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !validCSRF(r) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+
+		err := server.endSession(w, r)
+		if err != nil {
+			log.Ctx(r.Context()).Error().Err(err).Str("source", "http_login").Msg("cant end session")
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+	}
+}
+
+// Redirects to the identity provider.
+func OIDCLoginHandler(server *Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if server.oidc == nil {
+			http.NotFound(w, r)
+			return
+		}
+
+		url, err := server.oidc.authorize(r.Context(), w, r.URL.Query().Get("next"), server.config.SessionCookieSecure)
+		if err != nil {
+			log.Ctx(r.Context()).Error().Err(err).Str("source", "http_login").Msg("cant start oidc login")
+			renderLogin(server, w, r, "identity provider is not available", http.StatusBadGateway)
+			return
+		}
+
+		http.Redirect(w, r, url, http.StatusFound)
+	}
+}
+
+// Signs in the user returned by the identity provider.
+func OIDCCallbackHandler(server *Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if server.oidc == nil {
+			http.NotFound(w, r)
+			return
+		}
+
+		subject, username, scope, next, err := server.oidc.callback(w, r)
+		if errors.Is(err, errNoGroup) {
+			renderLogin(server, w, r, "you are not allowed to log in", http.StatusForbidden)
+			return
+		}
+		if err != nil {
+			log.Ctx(r.Context()).Warn().Err(err).Str("source", "http_login").Msg("cant complete oidc login")
+			renderLogin(server, w, r, "login failed, try again", http.StatusUnauthorized)
+			return
+		}
+
+		user, err := server.store.UpsertSubjectUser(r.Context(), subject, username, scope)
+		if errors.Is(err, ErrUsernameTaken) {
+			log.Ctx(r.Context()).Warn().Str("source", "http_login").Msgf("oidc username %s belongs to another user", username)
+			renderLogin(server, w, r, "username "+username+" belongs to another account, ask an admin", http.StatusConflict)
+			return
+		}
+		if err != nil {
+			log.Ctx(r.Context()).Error().Err(err).Str("source", "http_login").Msg("cant add oidc user")
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		err = server.startSession(w, r, user)
+		if err != nil {
+			log.Ctx(r.Context()).Error().Err(err).Str("source", "http_login").Msg("cant start session")
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		http.Redirect(w, r, next, http.StatusSeeOther)
+	}
+}
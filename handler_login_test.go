@@ -0,0 +1,183 @@
+package tracker
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// Keeps one user and its sessions in memory.
+type sessionStore struct {
+	TorrentStorable
+	user     User
+	sessions map[string]uuid.UUID
+}
+
+func (s *sessionStore) UserByUsername(ctx context.Context, username string) (User, error) {
+	if username != s.user.Username {
+		return User{}, pgx.ErrNoRows
+	}
+	return s.user, nil
+}
+
+func (s *sessionStore) AddSession(ctx context.Context, userID uuid.UUID, hash []byte, expiresAt time.Time) error {
+	s.sessions[string(hash)] = userID
+	return nil
+}
+
+func (s *sessionStore) SessionUser(ctx context.Context, hash []byte) (User, error) {
+	if _, ok := s.sessions[string(hash)]; !ok {
+		return User{}, pgx.ErrNoRows
+	}
+	return s.user, nil
+}
+
+func (s *sessionStore) DeleteSession(ctx context.Context, hash []byte) error {
+	delete(s.sessions, string(hash))
+	return nil
+}
+
+func TestLogin(t *testing.T) {
+	hash, err := HashPassword("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := &sessionStore{
+		user:     User{ID: uuid.Must(uuid.NewV4()), Username: "alice", PasswordHash: hash, Scope: "read"},
+		sessions: map[string]uuid.UUID{},
+	}
+	config := NewServerConfig("", "", "", "templates")
+	config.RequireLogin = true
+	server := &Server{config: config, store: store}
+
+	protected := LoginRequiredMiddleware(server)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal, _ := PrincipalFromContext(r.Context())
+		w.Write([]byte(principal.Name))
+	}))
+
+	login := func(csrf string, form url.Values) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r.AddCookie(&http.Cookie{Name: csrfCookie, Value: csrf})
+		w := httptest.NewRecorder()
+		LoginHandler(server).ServeHTTP(w, r)
+		return w
+	}
+
+	// anonymous requests are redirected to the login page
+	w := httptest.NewRecorder()
+	protected.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/torrent/1", nil))
+	if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/login?next=%2Ftorrent%2F1" {
+		t.Fatalf("want redirect to login, got %v %s", w.Code, w.Header().Get("Location"))
+	}
+
+	form := url.Values{"username": {"alice"}, "password": {"correct horse"}, "next": {"/torrent/1"}, csrfField: {"csrf"}}
+
+	if w := login("other", form); w.Code != http.StatusForbidden {
+		t.Errorf("csrf mismatch: want: %v, got %v", http.StatusForbidden, w.Code)
+	}
+
+	wrong := url.Values{"username": {"alice"}, "password": {"battery staple"}, csrfField: {"csrf"}}
+	if w := login("csrf", wrong); w.Code != http.StatusUnauthorized {
+		t.Errorf("wrong password: want: %v, got %v", http.StatusUnauthorized, w.Code)
+	}
+
+	unknown := url.Values{"username": {"bob"}, "password": {"correct horse"}, csrfField: {"csrf"}}
+	if w := login("csrf", unknown); w.Code != http.StatusUnauthorized {
+		t.Errorf("unknown user: want: %v, got %v", http.StatusUnauthorized, w.Code)
+	}
+
+	w = login("csrf", form)
+	if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/torrent/1" {
+		t.Fatalf("want redirect to next, got %v %s", w.Code, w.Header().Get("Location"))
+	}
+	var session *http.Cookie
+	for _, c := range w.Result().Cookies() {
+		if c.Name == sessionCookie {
+			session = c
+		}
+	}
+	if session == nil || !session.HttpOnly || !session.Secure {
+		t.Fatalf("want secure http only session cookie, got %v", session)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/torrent/1", nil)
+	r.AddCookie(session)
+	w = httptest.NewRecorder()
+	protected.ServeHTTP(w, r)
+	if w.Code != http.StatusOK || w.Body.String() != "alice" {
+		t.Fatalf("want: alice, got %v %s", w.Code, w.Body.String())
+	}
+
+	// state changing requests need the csrf token too
+	r = httptest.NewRequest(http.MethodPost, "/torrent/1", nil)
+	r.AddCookie(session)
+	r.AddCookie(&http.Cookie{Name: csrfCookie, Value: "csrf"})
+	if _, ok, err := server.authenticate(r); ok || !errors.Is(err, errInvalidCSRF) {
+		t.Errorf("session authenticated a post request without csrf token: %v", err)
+	}
+	w = httptest.NewRecorder()
+	AuthMiddleware(server, ScopeRead)(protected).ServeHTTP(w, r)
+	if w.Code != http.StatusForbidden || w.Header().Get("WWW-Authenticate") != "" {
+		t.Errorf("want 403 without a password prompt, got %v %v", w.Code, w.Header())
+	}
+	r.Header.Set(csrfHeader, "csrf")
+	if _, ok, _ := server.authenticate(r); !ok {
+		t.Error("session did not authenticate a post request with csrf token")
+	}
+
+	// the body of a request without a session is not read for the csrf token
+	body := &countingReader{Reader: strings.NewReader(csrfField + "=csrf")}
+	r = httptest.NewRequest(http.MethodPost, "/upload", body)
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.AddCookie(&http.Cookie{Name: csrfCookie, Value: "csrf"})
+	if _, ok, _ := server.authenticate(r); ok || body.n != 0 {
+		t.Errorf("want unauthenticated without reading the body, read %d bytes", body.n)
+	}
+
+	r = httptest.NewRequest(http.MethodPost, "/logout", strings.NewReader(csrfField+"=csrf"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.AddCookie(&http.Cookie{Name: csrfCookie, Value: "csrf"})
+	r.AddCookie(session)
+	w = httptest.NewRecorder()
+	LogoutHandler(server).ServeHTTP(w, r)
+	if w.Code != http.StatusSeeOther || len(store.sessions) != 0 {
+		t.Fatalf("want session removed, got %v with %d sessions", w.Code, len(store.sessions))
+	}
+}
+
+func TestSafeRedirect(t *testing.T) {
+	tests := map[string]string{
+		"/torrent/1":          "/torrent/1",
+		"":                    "/",
+		"https://example.com": "/",
+		"//example.com":       "/",
+		"/\\example.com":      "/",
+	}
+	for next, want := range tests {
+		if got := safeRedirect(next); got != want {
+			t.Errorf("%q: want: %v, got %v", next, want, got)
+		}
+	}
+}
+
+// Counts the bytes read from Reader.
+type countingReader struct {
+	io.Reader
+	n int
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.Reader.Read(p)
+	cr.n += n
+	return n, err
+}
@@ -0,0 +1,20 @@
+package tracker
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+// OpenAPI specification of the JSON API.
+// handler_api_test.go checks the handlers against it.
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+func OpenAPIHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(openAPISpec)
+	}
+}
@@ -13,9 +13,13 @@ func ScrapeHandler(server *Server) http.HandlerFunc {
 
 		metric.TrackerScrape.Inc()
 
+		if !server.checkPasskey(w, r) {
+			return
+		}
+
 		infoHash, ok := r.URL.Query()["info_hash"]
 		if !ok {
-			log.Error().Str("source", "http_scrape").Msg("info_hash is not present")
+			log.Ctx(ctx).Error().Str("source", "http_scrape").Msg("info_hash is not present")
 			failure := ErrorResponse{
 				FailureReason: "info_hash is not present",
 			}
@@ -30,7 +34,7 @@ func ScrapeHandler(server *Server) http.HandlerFunc {
 
 		torrents, err := server.store.Scrape(ctx, hashes)
 		if err != nil {
-			log.Error().Err(err).Str("source", "http_scrape").Msg("unable to fetch torrents")
+			log.Ctx(ctx).Error().Err(err).Str("source", "http_scrape").Msg("unable to fetch torrents")
 			failure := ErrorResponse{
 				FailureReason: "internal server error",
 			}
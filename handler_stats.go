@@ -0,0 +1,48 @@
+package tracker
+
+import (
+	"html/template"
+	"net/http"
+	"path/filepath"
+
+	"github.com/rs/zerolog/log"
+)
+
+func StatsHandler(server *Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+
+		stats, err := server.Stats(r.Context())
+		if err != nil {
+			log.Ctx(r.Context()).Error().Err(err).Msg("cant get stats in stats")
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		tmpl, err := template.ParseFiles(filepath.Join(server.config.TemplatePath, "stats.html"))
+		if err != nil {
+			log.Ctx(r.Context()).Error().Err(err).Msg("cant parse template in stats")
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		// todo: make a struct for view
+		dto := map[string]interface{}{
+			"Stats": stats,
+		}
+
+		_, err = server.headerView(w, r, dto)
+		if err != nil {
+			log.Ctx(r.Context()).Error().Err(err).Msg("cant get session in stats")
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		err = tmpl.Execute(w, dto)
+		if err != nil {
+			log.Ctx(r.Context()).Error().Err(err).Msg("cant execute template in stats")
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package tracker
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Counts how often the stats are computed.
+type statsStore struct {
+	TorrentStorable
+	calls int
+}
+
+func (s *statsStore) Stats(ctx context.Context) (Stats, error) {
+	s.calls++
+	return Stats{
+		Torrents:  2,
+		Peers:     4,
+		IPv4Peers: 3,
+		IPv6Peers: 1,
+		Clients:   []ClientCount{{Name: "qBittorrent", Peers: 3}, {Name: "Transmission", Peers: 1}},
+	}, nil
+}
+
+func TestStats(t *testing.T) {
+	store := &statsStore{}
+	server := &Server{config: NewServerConfig("", "", "", "templates"), store: store}
+
+	w := httptest.NewRecorder()
+	StatsHandler(server)(w, httptest.NewRequest(http.MethodGet, "/stats", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("want: %v, got %v", http.StatusOK, w.Code)
+	}
+	for _, want := range []string{"<td>Transmission</td>", "1 (25.0%)"} {
+		if !strings.Contains(w.Body.String(), want) {
+			t.Errorf("want %q in page", want)
+		}
+	}
+
+	// requests read the cached stats
+	w = httptest.NewRecorder()
+	APIStatsHandler(server)(w, httptest.NewRequest(http.MethodGet, "/api/v1/stats", nil))
+	if w.Code != http.StatusOK || store.calls != 1 {
+		t.Fatalf("want cached stats, got %v after %d computations", w.Code, store.calls)
+	}
+
+	// the announce rate is measured between refreshes
+	server.statsCache.stats.UpdatedAt = time.Now().Add(-2 * time.Minute)
+	for i := 0; i < 10; i++ {
+		server.announces.Add(1)
+	}
+	stats, err := server.RefreshStats(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(stats.AnnouncesPerMinute-5) > 0.1 {
+		t.Errorf("want 5 announces per minute, got %f", stats.AnnouncesPerMinute)
+	}
+}
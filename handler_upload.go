@@ -0,0 +1,188 @@
+package tracker
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+
+	"github.com/rs/zerolog/log"
+	"github.com/salimnassim/tracker/metainfo"
+	"github.com/salimnassim/tracker/metric"
+)
+
+const (
+	// Maximum size of an uploaded .torrent file.
+	maxTorrentSize = 10 << 20
+	// Room for the other fields and the part headers of an upload form.
+	formOverhead = 1 << 20
+)
+
+// Error in the uploaded file, shown to the uploader.
+type uploadError struct {
+	err error
+}
+
+func (e uploadError) Error() string {
+	return e.err.Error()
+}
+
+// Reads a .torrent file from the torrent field of a multipart form
+// or from the request body.
+func readUpload(w http.ResponseWriter, r *http.Request) ([]byte, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "multipart/form-data" {
+		data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxTorrentSize))
+		if err != nil {
+			return nil, uploadError{fmt.Errorf("torrent is too large")}
+		}
+		return data, nil
+	}
+
+	// parsing the form reads the whole body, so it is limited before
+	r.Body = http.MaxBytesReader(w, r.Body, maxTorrentSize+formOverhead)
+	file, header, err := r.FormFile("torrent")
+	var maxErr *http.MaxBytesError
+	if errors.As(err, &maxErr) {
+		return nil, uploadError{fmt.Errorf("torrent is too large")}
+	}
+	if err != nil {
+		return nil, uploadError{fmt.Errorf("torrent file is missing")}
+	}
+	defer file.Close()
+	if header.Size > maxTorrentSize {
+		return nil, uploadError{fmt.Errorf("torrent is too large")}
+	}
+	return io.ReadAll(file)
+}
+
+// Parses an uploaded .torrent file and stores its metainfo.
+// The torrent is created if nothing has announced it yet. Category and
+// comma separated tags are read from the form or query string.
+func (sv *Server) upload(w http.ResponseWriter, r *http.Request) (Torrent, error) {
+	ctx := r.Context()
+
+	data, err := readUpload(w, r)
+	if err != nil {
+		return Torrent{}, err
+	}
+
+	m, err := metainfo.Parse(data)
+	if err != nil {
+		return Torrent{}, uploadError{fmt.Errorf("torrent is not valid: %w", err)}
+	}
+
+	category := r.FormValue("category")
+	if category != "" && !ValidLabel(category) {
+		return Torrent{}, uploadError{fmt.Errorf("category is not valid")}
+	}
+	tags := ParseTags(r.FormValue("tags"))
+	for _, tag := range tags {
+		if !ValidLabel(tag) {
+			return Torrent{}, uploadError{fmt.Errorf("tag %q is not valid", tag)}
+		}
+	}
+
+	torrent, created, err := sv.store.GetOrAddTorrent(ctx, m.InfoHash[:])
+	if err != nil {
+		return Torrent{}, err
+	}
+	if created {
+		metric.TrackerTorrents.Inc()
+	}
+
+	principal, _ := PrincipalFromContext(ctx)
+	updated, err := sv.store.AddMetaInfo(ctx, torrent.ID, m, principal.Name)
+	if err != nil {
+		return Torrent{}, err
+	}
+
+	if category != "" || len(tags) > 0 {
+		updated, err = sv.store.UpdateTorrentLabels(ctx, torrent.ID, updated.Name, category, tags)
+		if err != nil {
+			return Torrent{}, err
+		}
+	}
+
+	sv.audit(r, "torrent.upload", torrent.ID.String(),
+		map[string]any{"name": torrent.Name, "size": torrent.Size, "category": torrent.Category, "tags": torrent.Tags},
+		map[string]any{"name": updated.Name, "size": updated.Size, "category": updated.Category, "tags": updated.Tags},
+		map[string]any{"info_hash": fmt.Sprintf("%x", m.InfoHash), "files": len(m.Files)})
+
+	return updated, nil
+}
+
+// Shows the upload form on GET and uploads on POST.
+func UploadHandler(server *Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		render := func(message string, statusCode int) {
+			tmpl, err := template.ParseFiles(filepath.Join(server.config.TemplatePath, "upload.html"))
+			if err != nil {
+				log.Ctx(r.Context()).Error().Err(err).Msg("cant parse template in upload")
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+
+			token, err := server.csrfToken(w, r)
+			if err != nil {
+				log.Ctx(r.Context()).Error().Err(err).Msg("cant create csrf token in upload")
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+
+			dto := map[string]interface{}{
+				"CSRFToken": token,
+				"Message":   message,
+			}
+
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.WriteHeader(statusCode)
+			err = tmpl.Execute(w, dto)
+			if err != nil {
+				log.Ctx(r.Context()).Error().Err(err).Msg("cant execute template in upload")
+				return
+			}
+		}
+
+		if r.Method != http.MethodPost {
+			render("", http.StatusOK)
+			return
+		}
+
+		torrent, err := server.upload(w, r)
+		var uerr uploadError
+		if errors.As(err, &uerr) {
+			render(uerr.Error(), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			log.Ctx(r.Context()).Error().Err(err).Msg("cant upload torrent")
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		http.Redirect(w, r, "/torrent/"+torrent.ID.String(), http.StatusSeeOther)
+	}
+}
+
+// Uploads a .torrent file sent as the request body or as the torrent field of a form.
+func AdminUploadTorrentHandler(server *Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		torrent, err := server.upload(w, r)
+		var uerr uploadError
+		if errors.As(err, &uerr) {
+			replyJSONError(w, uerr.Error(), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			log.Ctx(r.Context()).Error().Err(err).Str("source", "http_admin").Msg("cant upload torrent")
+			replyJSONError(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		replyJSON(w, &torrent, http.StatusCreated)
+	}
+}
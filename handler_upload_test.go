@@ -0,0 +1,97 @@
+package tracker
+
+import (
+	"bytes"
+	"context"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofrs/uuid"
+	"github.com/salimnassim/tracker/metainfo"
+)
+
+// Keeps uploaded torrents in memory.
+type uploadStore struct {
+	TorrentStorable
+	torrents map[string]Torrent
+	audits   []AuditLog
+}
+
+func (s *uploadStore) GetOrAddTorrent(ctx context.Context, infoHash []byte) (Torrent, bool, error) {
+	if t, ok := s.torrents[string(infoHash)]; ok {
+		return t, false, nil
+	}
+	t := Torrent{ID: uuid.Must(uuid.NewV4()), InfoHash: infoHash}
+	s.torrents[string(infoHash)] = t
+	return t, true, nil
+}
+
+func (s *uploadStore) AddMetaInfo(ctx context.Context, torrentID uuid.UUID, m *metainfo.MetaInfo, uploadedBy string) (Torrent, error) {
+	t := s.torrents[string(m.InfoHash[:])]
+	t.Name, t.Size, t.Uploaded = m.Name, m.Length, true
+	s.torrents[string(m.InfoHash[:])] = t
+	return t, nil
+}
+
+func (s *uploadStore) AddAuditLog(ctx context.Context, entry AuditLog) error {
+	s.audits = append(s.audits, entry)
+	return nil
+}
+
+func TestUpload(t *testing.T) {
+	store := &uploadStore{torrents: map[string]Torrent{}}
+	server := &Server{config: NewServerConfig("", "", "", "templates"), store: store}
+
+	torrent := "d4:infod6:lengthi2048e4:name8:file.iso12:piece lengthi16384e6:pieces20:" + strings.Repeat("a", 20) + "ee"
+
+	w := httptest.NewRecorder()
+	AdminUploadTorrentHandler(server).ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/admin/torrents", strings.NewReader(torrent)))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("want: %v, got %v: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), `"name":"file.iso","size":2048`) {
+		t.Errorf("response has no name and size: %s", w.Body.String())
+	}
+	if len(store.audits) != 1 || store.audits[0].Action != "torrent.upload" {
+		t.Errorf("want torrent.upload audit log, got %+v", store.audits)
+	}
+
+	// same file through the form updates the same torrent
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, _ := mw.CreateFormFile("torrent", "file.torrent")
+	fw.Write([]byte(torrent))
+	mw.Close()
+
+	r := httptest.NewRequest(http.MethodPost, "/upload", &body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	w = httptest.NewRecorder()
+	UploadHandler(server).ServeHTTP(w, r)
+	if w.Code != http.StatusSeeOther || len(store.torrents) != 1 {
+		t.Fatalf("want redirect and 1 torrent, got %v and %d", w.Code, len(store.torrents))
+	}
+
+	w = httptest.NewRecorder()
+	AdminUploadTorrentHandler(server).ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/admin/torrents", strings.NewReader("d4:infoi1ee")))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("invalid torrent: want: %v, got %v", http.StatusBadRequest, w.Code)
+	}
+
+	// the form body is limited before it is parsed
+	body.Reset()
+	mw = multipart.NewWriter(&body)
+	fw, _ = mw.CreateFormFile("torrent", "large.torrent")
+	fw.Write(bytes.Repeat([]byte("a"), maxTorrentSize+formOverhead))
+	mw.Close()
+
+	r = httptest.NewRequest(http.MethodPost, "/api/admin/torrents", &body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	w = httptest.NewRecorder()
+	AdminUploadTorrentHandler(server).ServeHTTP(w, r)
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "torrent is too large") {
+		t.Errorf("large form: want too large, got %v %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,150 @@
+package tracker
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/salimnassim/tracker/migrations"
+)
+
+const (
+	HealthOK       = "ok"
+	HealthDegraded = "degraded"
+)
+
+// Last runs of the background tasks started with RunTask.
+type taskHeartbeats struct {
+	mu    sync.Mutex
+	tasks map[string]*taskHeartbeat
+}
+
+type taskHeartbeat struct {
+	interval time.Duration
+	lastRun  time.Time
+	ran      bool
+}
+
+// Registers task name running every interval. The start counts as the first heartbeat.
+func (hb *taskHeartbeats) add(name string, interval time.Duration, now time.Time) {
+	hb.mu.Lock()
+	defer hb.mu.Unlock()
+	if hb.tasks == nil {
+		hb.tasks = map[string]*taskHeartbeat{}
+	}
+	hb.tasks[name] = &taskHeartbeat{interval: interval, lastRun: now}
+}
+
+// Records a finished run of task name.
+func (hb *taskHeartbeats) beat(name string, now time.Time) {
+	hb.mu.Lock()
+	defer hb.mu.Unlock()
+	if t, ok := hb.tasks[name]; ok {
+		t.lastRun = now
+		t.ran = true
+	}
+}
+
+// Readiness of the server and its dependencies.
+type Readiness struct {
+	Status      string            `json:"status"`
+	Database    DatabaseHealth    `json:"database"`
+	Migrations  MigrationHealth   `json:"migrations"`
+	Tasks       []TaskHealth      `json:"tasks"`
+	WriteBehind WriteBehindHealth `json:"write_behind"`
+}
+
+type DatabaseHealth struct {
+	Status  string  `json:"status"`
+	Latency float64 `json:"latency_seconds"`
+	Error   string  `json:"error,omitempty"`
+}
+
+// Version is the applied migration, Latest the newest one known to this build.
+type MigrationHealth struct {
+	Status  string `json:"status"`
+	Version int64  `json:"version"`
+	Latest  int64  `json:"latest"`
+	Dirty   bool   `json:"dirty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// A task is degraded if it has not finished a run in two intervals.
+type TaskHealth struct {
+	Status   string     `json:"status"`
+	Name     string     `json:"name"`
+	Interval string     `json:"interval"`
+	LastRun  *time.Time `json:"last_run"`
+}
+
+// The write-behind queue is degraded once it is full and announces wait for a flush.
+type WriteBehindHealth struct {
+	Status    string `json:"status"`
+	Enabled   bool   `json:"enabled"`
+	Pending   int    `json:"pending"`
+	QueueSize int    `json:"queue_size"`
+}
+
+// Checks the store, the schema version, background tasks and the write-behind queue.
+// Store checks are cancelled after the configured health timeout.
+func (sv *Server) Readiness(ctx context.Context) Readiness {
+	ctx, cancel := context.WithTimeout(ctx, sv.config.HealthTimeout)
+	defer cancel()
+
+	readiness := Readiness{Status: HealthOK}
+	degrade := func(status *string, ok bool) {
+		*status = HealthOK
+		if !ok {
+			*status = HealthDegraded
+			readiness.Status = HealthDegraded
+		}
+	}
+
+	start := time.Now()
+	_, err := sv.store.Ping(ctx)
+	readiness.Database.Latency = time.Since(start).Seconds()
+	// errors of the driver can carry hosts and users, they are only logged
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Str("source", "health").Msg("cant ping database")
+		readiness.Database.Error = "database is not reachable"
+	}
+	degrade(&readiness.Database.Status, err == nil)
+
+	// a database ahead of this build is fine while it is being rolled out
+	readiness.Migrations.Latest = migrations.Latest()
+	readiness.Migrations.Version, readiness.Migrations.Dirty, err = sv.store.MigrationVersion(ctx)
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Str("source", "health").Msg("cant get migration version")
+		readiness.Migrations.Error = "migration version is not available"
+	}
+	degrade(&readiness.Migrations.Status, err == nil && !readiness.Migrations.Dirty && readiness.Migrations.Version >= readiness.Migrations.Latest)
+
+	now := time.Now()
+	sv.tasks.mu.Lock()
+	readiness.Tasks = make([]TaskHealth, 0, len(sv.tasks.tasks))
+	for name, t := range sv.tasks.tasks {
+		task := TaskHealth{Name: name, Interval: t.interval.String()}
+		if t.ran {
+			lastRun := t.lastRun
+			task.LastRun = &lastRun
+		}
+		degrade(&task.Status, now.Sub(t.lastRun) <= 2*t.interval)
+		readiness.Tasks = append(readiness.Tasks, task)
+	}
+	sv.tasks.mu.Unlock()
+	slices.SortFunc(readiness.Tasks, func(a, b TaskHealth) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	if sv.writeBehind != nil {
+		readiness.WriteBehind.Enabled = true
+		readiness.WriteBehind.Pending = sv.writeBehind.Pending()
+		readiness.WriteBehind.QueueSize = sv.writeBehind.config.QueueSize
+	}
+	degrade(&readiness.WriteBehind.Status, !readiness.WriteBehind.Enabled || readiness.WriteBehind.Pending < readiness.WriteBehind.QueueSize)
+
+	return readiness
+}
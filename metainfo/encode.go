@@ -0,0 +1,60 @@
+package metainfo
+
+import (
+	"bytes"
+	"errors"
+	"slices"
+	"strings"
+
+	"github.com/cristalhq/bencode"
+)
+
+// Encodes a .torrent file with the raw info dictionary and announce URLs.
+// The info dictionary is written as is so the info hash does not change.
+// extra holds the other top level keys of the uploaded file, e.g. url-list,
+// and may be empty. announceList is omitted if empty.
+func Encode(info []byte, extra []byte, announce string, announceList [][]string) ([]byte, error) {
+	if len(info) == 0 || info[0] != 'd' {
+		return nil, errors.New("info is not a dictionary")
+	}
+
+	var dict []entry
+	if len(extra) > 0 {
+		var err error
+		dict, err = entries(extra)
+		if err != nil {
+			return nil, err
+		}
+	}
+	dict = slices.DeleteFunc(dict, func(e entry) bool {
+		return e.key == "info" || e.key == "announce" || e.key == "announce-list"
+	})
+
+	value, err := bencode.Marshal(announce)
+	if err != nil {
+		return nil, err
+	}
+	dict = append(dict, entry{key: "announce", value: value})
+	if len(announceList) > 0 {
+		value, err := bencode.Marshal(announceList)
+		if err != nil {
+			return nil, err
+		}
+		dict = append(dict, entry{key: "announce-list", value: value})
+	}
+	dict = append(dict, entry{key: "info", value: info})
+
+	// keys of a dictionary are sorted as raw strings
+	slices.SortFunc(dict, func(a, b entry) int {
+		return strings.Compare(a.key, b.key)
+	})
+
+	var buf bytes.Buffer
+	buf.WriteByte('d')
+	for _, e := range dict {
+		writeEntry(&buf, e)
+	}
+	buf.WriteByte('e')
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,316 @@
+// Package metainfo parses .torrent files.
+package metainfo
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/cristalhq/bencode"
+)
+
+var (
+	ErrNotDict  = errors.New("metainfo is not a dictionary")
+	ErrNoInfo   = errors.New("metainfo has no info dictionary")
+	ErrTrailing = errors.New("metainfo has trailing data")
+)
+
+type File struct {
+	// Path of the file inside the torrent, separated by slashes.
+	Path   string `json:"path"`
+	Length int64  `json:"length"`
+}
+
+type MetaInfo struct {
+	// SHA-1 of the bencoded info dictionary (v1 info hash).
+	InfoHash [20]byte
+	// Raw bencoded info dictionary as it appeared in the file.
+	Info []byte
+	// Raw bencoded dictionary of the other top level keys except the announce URLs,
+	// e.g. url-list for web seeds, kept to write them back on download.
+	Extra []byte
+
+	Name        string
+	PieceLength int64
+	Pieces      int
+	Private     bool
+	// Total size of all files.
+	Length int64
+	// Files of the torrent. Single file torrents have one file named after the torrent.
+	Files []File
+
+	Announce     string
+	AnnounceList [][]string
+	Comment      string
+	CreatedBy    string
+	CreationDate time.Time
+}
+
+// Parses a bencoded .torrent file.
+func Parse(data []byte) (*MetaInfo, error) {
+	info, err := infoSpan(data)
+	if err != nil {
+		return nil, err
+	}
+
+	extra, err := extraSpan(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var root map[string]any
+	err = bencode.Unmarshal(data, &root)
+	if err != nil {
+		return nil, err
+	}
+	var dict map[string]any
+	err = bencode.Unmarshal(info, &dict)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &MetaInfo{
+		InfoHash:     sha1.Sum(info),
+		Info:         info,
+		Extra:        extra,
+		Announce:     str(root["announce"]),
+		Comment:      str(root["comment"]),
+		CreatedBy:    str(root["created by"]),
+		AnnounceList: announceList(root["announce-list"]),
+	}
+	if date, ok := root["creation date"].(int64); ok {
+		m.CreationDate = time.Unix(date, 0).UTC()
+	}
+
+	m.Name = str(dict["name"])
+	if m.Name == "" {
+		return nil, errors.New("info has no name")
+	}
+
+	var ok bool
+	m.PieceLength, ok = dict["piece length"].(int64)
+	if !ok || m.PieceLength <= 0 {
+		return nil, errors.New("info has no piece length")
+	}
+
+	pieces, ok := dict["pieces"].([]byte)
+	if !ok || len(pieces)%sha1.Size != 0 {
+		return nil, errors.New("info pieces are not valid")
+	}
+	m.Pieces = len(pieces) / sha1.Size
+
+	private, _ := dict["private"].(int64)
+	m.Private = private == 1
+
+	if length, ok := dict["length"].(int64); ok {
+		if length < 0 {
+			return nil, errors.New("info length is not valid")
+		}
+		m.Length = length
+		m.Files = []File{{Path: m.Name, Length: length}}
+		return m, nil
+	}
+
+	files, ok := dict["files"].([]any)
+	if !ok || len(files) == 0 {
+		return nil, errors.New("info has no length or files")
+	}
+	for i, f := range files {
+		file, ok := f.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("file %d is not a dictionary", i)
+		}
+		length, ok := file["length"].(int64)
+		if !ok || length < 0 {
+			return nil, fmt.Errorf("file %d has no length", i)
+		}
+		parts, ok := file["path"].([]any)
+		if !ok || len(parts) == 0 {
+			return nil, fmt.Errorf("file %d has no path", i)
+		}
+		elems := make([]string, 0, len(parts))
+		for _, p := range parts {
+			elems = append(elems, str(p))
+		}
+		m.Files = append(m.Files, File{Path: path.Join(elems...), Length: length})
+		m.Length += length
+	}
+
+	return m, nil
+}
+
+func str(v any) string {
+	b, _ := v.([]byte)
+	return string(b)
+}
+
+func announceList(v any) [][]string {
+	tiers, _ := v.([]any)
+	var list [][]string
+	for _, t := range tiers {
+		urls, _ := t.([]any)
+		var tier []string
+		for _, u := range urls {
+			if s := str(u); s != "" {
+				tier = append(tier, s)
+			}
+		}
+		if len(tier) > 0 {
+			list = append(list, tier)
+		}
+	}
+	return list
+}
+
+// Key and raw value of a dictionary entry.
+type entry struct {
+	key   string
+	value []byte
+}
+
+// Returns the entries of the top level dictionary of data with their raw values.
+func entries(data []byte) ([]entry, error) {
+	if len(data) == 0 || data[0] != 'd' {
+		return nil, ErrNotDict
+	}
+
+	var dict []entry
+	i := 1
+	for i < len(data) && data[i] != 'e' {
+		key, next, err := readString(data, i)
+		if err != nil {
+			return nil, err
+		}
+		end, err := skip(data, next, 0)
+		if err != nil {
+			return nil, err
+		}
+		dict = append(dict, entry{key: key, value: data[next:end]})
+		i = end
+	}
+	if i >= len(data) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	if i+1 != len(data) {
+		return nil, ErrTrailing
+	}
+	return dict, nil
+}
+
+// Returns the raw bytes of the info value of the top level dictionary.
+// The info hash has to be computed over the original bytes, re-encoding a
+// decoded dictionary changes the hash of files that are not canonical.
+func infoSpan(data []byte) ([]byte, error) {
+	dict, err := entries(data)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range dict {
+		if e.key == "info" {
+			if e.value[0] != 'd' {
+				return nil, ErrNoInfo
+			}
+			return e.value, nil
+		}
+	}
+	return nil, ErrNoInfo
+}
+
+// Returns the top level keys of data other than info and the announce keys,
+// e.g. url-list, comment or creation date, as a bencoded dictionary.
+func extraSpan(data []byte) ([]byte, error) {
+	dict, err := entries(data)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	buf.WriteByte('d')
+	for _, e := range dict {
+		if e.key == "info" || e.key == "announce" || e.key == "announce-list" {
+			continue
+		}
+		writeEntry(&buf, e)
+	}
+	buf.WriteByte('e')
+	return buf.Bytes(), nil
+}
+
+func writeEntry(buf *bytes.Buffer, e entry) {
+	buf.WriteString(strconv.Itoa(len(e.key)))
+	buf.WriteByte(':')
+	buf.WriteString(e.key)
+	buf.Write(e.value)
+}
+
+// Maximum nesting of lists and dictionaries.
+const maxDepth = 64
+
+// Returns the index after the value starting at i.
+func skip(data []byte, i int, depth int) (int, error) {
+	if i >= len(data) {
+		return 0, io.ErrUnexpectedEOF
+	}
+	if depth > maxDepth {
+		return 0, errors.New("metainfo is nested too deep")
+	}
+
+	switch c := data[i]; {
+	case c == 'i':
+		end := bytes.IndexByte(data[i:], 'e')
+		if end < 0 {
+			return 0, io.ErrUnexpectedEOF
+		}
+		_, err := strconv.ParseInt(string(data[i+1:i+end]), 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("integer at %d is not valid", i)
+		}
+		return i + end + 1, nil
+	case c == 'l' || c == 'd':
+		i++
+		for i < len(data) && data[i] != 'e' {
+			if c == 'd' {
+				_, next, err := readString(data, i)
+				if err != nil {
+					return 0, err
+				}
+				i = next
+			}
+			next, err := skip(data, i, depth+1)
+			if err != nil {
+				return 0, err
+			}
+			i = next
+		}
+		if i >= len(data) {
+			return 0, io.ErrUnexpectedEOF
+		}
+		return i + 1, nil
+	case c >= '0' && c <= '9':
+		_, next, err := readString(data, i)
+		return next, err
+	default:
+		return 0, fmt.Errorf("unexpected %q at %d", c, i)
+	}
+}
+
+// Reads the string starting at i and returns it and the index after it.
+func readString(data []byte, i int) (string, int, error) {
+	colon := bytes.IndexByte(data[i:], ':')
+	if colon < 0 {
+		return "", 0, io.ErrUnexpectedEOF
+	}
+	n, err := strconv.Atoi(string(data[i : i+colon]))
+	if err != nil || n < 0 {
+		return "", 0, fmt.Errorf("string length at %d is not valid", i)
+	}
+	start := i + colon + 1
+	if n > len(data)-start {
+		return "", 0, io.ErrUnexpectedEOF
+	}
+	return string(data[start : start+n]), start + n, nil
+}
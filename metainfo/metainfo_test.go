@@ -0,0 +1,130 @@
+package metainfo
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"strings"
+	"testing"
+)
+
+func TestParseSingleFile(t *testing.T) {
+	pieces := strings.Repeat("a", 40)
+	info := "d6:lengthi1000e4:name8:file.iso12:piece lengthi16384e6:pieces40:" + pieces + "7:privatei1ee"
+	data := "d8:announce30:http://localhost:9999/announce13:creation datei1700000000e4:info" + info + "e"
+
+	m, err := Parse([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.InfoHash != sha1.Sum([]byte(info)) {
+		t.Errorf("info hash is not the hash of the info dictionary")
+	}
+	if m.Name != "file.iso" || m.Length != 1000 || m.PieceLength != 16384 || m.Pieces != 2 || !m.Private {
+		t.Errorf("unexpected metainfo %+v", m)
+	}
+	if len(m.Files) != 1 || m.Files[0] != (File{Path: "file.iso", Length: 1000}) {
+		t.Errorf("unexpected files %+v", m.Files)
+	}
+	if m.Announce != "http://localhost:9999/announce" || m.CreationDate.Unix() != 1700000000 {
+		t.Errorf("unexpected announce %q or creation date %v", m.Announce, m.CreationDate)
+	}
+}
+
+func TestParseMultiFile(t *testing.T) {
+	// keys are not sorted, so re-encoding the decoded info would change the hash
+	info := "d4:name3:dir5:filesld6:lengthi10e4:pathl1:a5:b.txteed6:lengthi5e4:pathl5:c.txteee12:piece lengthi16384e6:pieces20:" + strings.Repeat("b", 20) + "e"
+	data := "d4:info" + info + "13:announce-listll5:udp:ae" + "l5:http:ee" + "e"
+
+	m, err := Parse([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.InfoHash != sha1.Sum([]byte(info)) {
+		t.Errorf("info hash is not the hash of the info dictionary")
+	}
+	if m.Length != 15 || len(m.Files) != 2 || m.Files[0].Path != "a/b.txt" || m.Files[1].Path != "c.txt" {
+		t.Errorf("unexpected metainfo %+v", m)
+	}
+	if len(m.AnnounceList) != 2 || m.AnnounceList[1][0] != "http:" {
+		t.Errorf("unexpected announce list %v", m.AnnounceList)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := []string{
+		"",
+		"le",
+		"d4:infoi1ee",
+		"d8:announce1:ae",
+		"d4:infod4:name1:a",
+		"d4:infod4:name1:a12:piece lengthi1e6:pieces20:" + strings.Repeat("b", 20) + "eee",
+		"d4:infod4:name1:a12:piece lengthi1e6:pieces3:abc6:lengthi1eee",
+		"d4:infod4:name1:a12:piece lengthi1e6:pieces20:" + strings.Repeat("b", 20) + "6:lengthi1eeee",
+		"d4:infod4:name99:ae",
+		"d4:infod4:name1:a6:lengthixeee",
+		strings.Repeat("l", 100),
+	}
+	for _, data := range tests {
+		_, err := Parse([]byte(data))
+		if err == nil {
+			t.Errorf("%q: want error", data)
+		}
+	}
+}
+
+func TestEncode(t *testing.T) {
+	// private flag and unsorted keys are kept
+	info := "d4:name8:file.iso6:lengthi1000e12:piece lengthi16384e6:pieces20:" + strings.Repeat("a", 20) + "7:privatei1ee"
+
+	data, err := Encode([]byte(info), nil, "http://localhost:9999/announce/abc", [][]string{{"http://localhost:9999/announce/abc"}, {"udp://backup:80"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.InfoHash != sha1.Sum([]byte(info)) || !m.Private {
+		t.Errorf("info changed: %+v", m)
+	}
+	if m.Announce != "http://localhost:9999/announce/abc" || len(m.AnnounceList) != 2 || m.AnnounceList[1][0] != "udp://backup:80" {
+		t.Errorf("unexpected announce %q and list %v", m.Announce, m.AnnounceList)
+	}
+
+	data, err = Encode([]byte(info), nil, "http://localhost:9999/announce", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(data, []byte("announce-list")) {
+		t.Errorf("want no announce-list, got %s", data)
+	}
+}
+
+func TestEncodeKeepsExtraKeys(t *testing.T) {
+	info := "d6:lengthi1000e4:name8:file.iso12:piece lengthi16384e6:pieces20:" + strings.Repeat("a", 20) + "e"
+	data := "d8:announce19:http://old/announce7:comment5:hello10:created by4:test13:creation datei1700000000e9:httpseedsl13:http://seed/ae4:info" + info + "8:url-listl19:http://mirror/file/ee"
+
+	m, err := Parse([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := Encode(m.Info, m.Extra, "http://localhost:9999/announce", [][]string{{"http://localhost:9999/announce"}, {"udp://backup:80"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// only the announce keys are replaced and keys stay sorted
+	want := "d8:announce30:http://localhost:9999/announce13:announce-listll30:http://localhost:9999/announceel15:udp://backup:80ee7:comment5:hello10:created by4:test13:creation datei1700000000e9:httpseedsl13:http://seed/ae4:info" + info + "8:url-listl19:http://mirror/file/ee"
+	if string(out) != want {
+		t.Errorf("want %s, got %s", want, out)
+	}
+
+	again, err := Parse(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.InfoHash != m.InfoHash || again.Comment != "hello" || again.CreationDate.Unix() != 1700000000 || !bytes.Equal(again.Extra, m.Extra) {
+		t.Errorf("round trip changed metainfo %+v", again)
+	}
+}
@@ -0,0 +1,16 @@
+package metric
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	// Duration of HTTP requests by route template, method and status code.
+	TrackerHTTPSeconds = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "tracker",
+		Name:      "http_request_seconds",
+		Help:      "The duration of HTTP requests",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method", "code"})
+)
@@ -0,0 +1,47 @@
+package metric
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	// Pending writes in the write-behind queue by queue name.
+	TrackerWriteBehindQueue = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "tracker",
+		Name:      "write_behind_queue",
+		Help:      "The number of pending writes in the write-behind queue",
+	}, []string{"queue"})
+	TrackerWriteBehindFlushed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "tracker",
+		Name:      "write_behind_flushed",
+		Help:      "The total number of writes flushed from the write-behind queue",
+	}, []string{"queue"})
+	TrackerWriteBehindDropped = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "tracker",
+		Name:      "write_behind_dropped",
+		Help:      "The total number of writes dropped because a flush failed",
+	}, []string{"queue"})
+	// Number of times a writer had to wait for the queue to drain.
+	TrackerWriteBehindBlocked = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "tracker",
+		Name:      "write_behind_blocked",
+		Help:      "The total number of writes that waited on a full write-behind queue",
+	})
+	TrackerWriteBehindFlushSeconds = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: "tracker",
+		Name:      "write_behind_flush_seconds",
+		Help:      "The duration of write-behind flushes",
+		Buckets:   prometheus.DefBuckets,
+	})
+)
+
+var (
+	// Duration of store calls by method and result (ok or error).
+	TrackerStoreSeconds = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "tracker",
+		Name:      "store_seconds",
+		Help:      "The duration of store calls",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "result"})
+)
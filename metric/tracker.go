@@ -35,3 +35,36 @@ var (
 		Help:      "The total number of scrape replies",
 	})
 )
+
+var (
+	// Current swarm state, updated with the stats.
+	TrackerSwarmTorrents = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: "tracker",
+		Name:      "swarm_torrents",
+		Help:      "The number of tracked torrents",
+	})
+	TrackerSwarmPeers = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: "tracker",
+		Name:      "swarm_peers",
+		Help:      "The number of active peers",
+	})
+	TrackerSwarmSeeders = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: "tracker",
+		Name:      "swarm_seeders",
+		Help:      "The number of seeders over all torrents",
+	})
+	TrackerSwarmLeechers = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: "tracker",
+		Name:      "swarm_leechers",
+		Help:      "The number of leechers over all torrents",
+	})
+)
+
+var (
+	// Failure responses to announces and scrapes by failure reason.
+	TrackerFailure = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "tracker",
+		Name:      "failure",
+		Help:      "The total number of failure responses by failure reason",
+	}, []string{"reason"})
+)
@@ -0,0 +1,197 @@
+package tracker
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+)
+
+type principalKey struct{}
+
+// Authenticated user or token of a request.
+type Principal struct {
+	Name  string
+	Scope Scope
+}
+
+// Returns the authenticated user of the request context.
+func PrincipalFromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	return p, ok
+}
+
+// Middleware that requires a principal with scope.
+// Requests are authenticated with an API token in the Authorization: Bearer
+// header, with the admin credentials using HTTP basic auth or with a web UI session.
+func AuthMiddleware(server *Server, scope Scope) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal, ok, err := server.authenticate(r)
+			if errors.Is(err, errInvalidCSRF) {
+				http.Error(w, err.Error(), http.StatusForbidden)
+				return
+			}
+			if err != nil {
+				log.Ctx(r.Context()).Error().Err(err).Str("source", "http_auth").Msg("cant authenticate request")
+				http.Error(w, "internal server error", http.StatusInternalServerError)
+				return
+			}
+			if !ok {
+				w.Header().Set("WWW-Authenticate", `Basic realm="tracker", charset="UTF-8"`)
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+			if !principal.Scope.Allows(scope) {
+				http.Error(w, "forbidden", http.StatusForbidden)
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), principalKey{}, principal)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// Returns the principal of r. ok is false if r has no valid credentials.
+// Unsafe requests with credentials a browser sends on its own but without
+// a valid CSRF token fail with errInvalidCSRF.
+func (sv *Server) authenticate(r *http.Request) (principal Principal, ok bool, err error) {
+	if secret, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); found {
+		token, err := sv.store.TokenByHash(r.Context(), HashTokenSecret(secret))
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Principal{}, false, nil
+		}
+		if err != nil {
+			return Principal{}, false, err
+		}
+
+		scope, err := ParseScope(token.Scope)
+		if err != nil {
+			return Principal{}, false, err
+		}
+
+		err = sv.store.TouchToken(r.Context(), token.ID)
+		if err != nil {
+			log.Ctx(r.Context()).Error().Err(err).Str("source", "http_auth").Msg("cant update token last used")
+		}
+
+		return Principal{Name: "token:" + token.Name, Scope: scope}, true, nil
+	}
+
+	unsafe := r.Method != http.MethodGet && r.Method != http.MethodHead
+
+	// browsers send cached basic auth credentials cross-site like cookies
+	username, password, found := r.BasicAuth()
+	if found && sv.config.isAdmin(username, password) {
+		if unsafe && browserRequest(r) && !validCSRF(r) {
+			return Principal{}, false, errInvalidCSRF
+		}
+		return Principal{Name: username, Scope: ScopeAdmin}, true, nil
+	}
+
+	user, found, err := sv.sessionUser(r)
+	if err != nil {
+		return Principal{}, false, err
+	}
+	if !found {
+		return Principal{}, false, nil
+	}
+
+	// sessions are cookies, so state changing requests, e.g. the upload form,
+	// also need the CSRF token. It is checked after the session so the body
+	// of unauthenticated requests is not read.
+	if unsafe && !validCSRF(r) {
+		return Principal{}, false, errInvalidCSRF
+	}
+
+	scope, err := ParseScope(user.Scope)
+	if err != nil {
+		return Principal{}, false, err
+	}
+	return Principal{Name: user.Username, Scope: scope}, true, nil
+}
+
+// Middleware that redirects to the login page if login is required
+// and the request is not authenticated.
+func LoginRequiredMiddleware(server *Server) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !server.config.RequireLogin {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			principal, ok, err := server.authenticate(r)
+			if errors.Is(err, errInvalidCSRF) {
+				http.Error(w, err.Error(), http.StatusForbidden)
+				return
+			}
+			if err != nil {
+				log.Ctx(r.Context()).Error().Err(err).Str("source", "http_auth").Msg("cant authenticate request")
+				http.Error(w, "internal server error", http.StatusInternalServerError)
+				return
+			}
+			if !ok {
+				http.Redirect(w, r, "/login?next="+url.QueryEscape(r.URL.RequestURI()), http.StatusSeeOther)
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), principalKey{}, principal)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// Middleware for the JSON API that requires a principal if login is required.
+// API clients cannot follow a redirect to the login page, so unauthenticated
+// requests get a 401 instead.
+func APILoginRequiredMiddleware(server *Server) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !server.config.RequireLogin {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			principal, ok, err := server.authenticate(r)
+			if errors.Is(err, errInvalidCSRF) {
+				replyJSONError(w, err.Error(), http.StatusForbidden)
+				return
+			}
+			if err != nil {
+				log.Ctx(r.Context()).Error().Err(err).Str("source", "http_auth").Msg("cant authenticate request")
+				replyJSONError(w, "internal server error", http.StatusInternalServerError)
+				return
+			}
+			if !ok {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="tracker"`)
+				replyJSONError(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), principalKey{}, principal)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// Compares credentials to the configured admin in constant time.
+func (c *ServerConfig) isAdmin(username string, password string) bool {
+	if c.AdminPassword == "" {
+		return false
+	}
+	eq := func(a, b string) int {
+		ha := sha256.Sum256([]byte(a))
+		hb := sha256.Sum256([]byte(b))
+		return subtle.ConstantTimeCompare(ha[:], hb[:])
+	}
+	return eq(username, c.AdminUsername)&eq(password, c.AdminPassword) == 1
+}
@@ -0,0 +1,146 @@
+package tracker
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofrs/uuid"
+	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v5"
+)
+
+type tokenStore struct {
+	TorrentStorable
+	hash  []byte
+	token APIToken
+}
+
+func (s *tokenStore) TokenByHash(ctx context.Context, hash []byte) (APIToken, error) {
+	if string(hash) != string(s.hash) {
+		return APIToken{}, pgx.ErrNoRows
+	}
+	return s.token, nil
+}
+
+func (s *tokenStore) TouchToken(ctx context.Context, tokenID uuid.UUID) error {
+	return nil
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	secret, hash, err := NewTokenSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &Server{
+		config: &ServerConfig{AdminUsername: "admin", AdminPassword: "hunter2"},
+		store: &tokenStore{
+			hash:  hash,
+			token: APIToken{ID: uuid.Must(uuid.NewV4()), Name: "ci", Scope: "read"},
+		},
+	}
+
+	handler := AuthMiddleware(server, ScopeModerate)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal, _ := PrincipalFromContext(r.Context())
+		w.Write([]byte(principal.Name))
+	}))
+
+	tests := []struct {
+		name string
+		auth func(r *http.Request)
+		want int
+	}{
+		{"none", func(r *http.Request) {}, http.StatusUnauthorized},
+		{"basic", func(r *http.Request) { r.SetBasicAuth("admin", "hunter2") }, http.StatusOK},
+		{"basic wrong password", func(r *http.Request) { r.SetBasicAuth("admin", "hunter3") }, http.StatusUnauthorized},
+		{"bearer read scope", func(r *http.Request) { r.Header.Set("Authorization", "Bearer "+secret) }, http.StatusForbidden},
+		{"bearer unknown", func(r *http.Request) { r.Header.Set("Authorization", "Bearer trk_nope") }, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		tt.auth(r)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != tt.want {
+			t.Errorf("%s: want: %v, got %v", tt.name, tt.want, w.Code)
+		}
+	}
+
+	// browsers resend basic credentials cross-site, so their unsafe requests need the csrf token
+	unsafe := []struct {
+		name   string
+		header func(r *http.Request)
+		want   int
+	}{
+		{"api client", func(r *http.Request) {}, http.StatusOK},
+		{"cross-site form", func(r *http.Request) { r.Header.Set("Origin", "https://evil.example") }, http.StatusForbidden},
+		{"cookie without token", func(r *http.Request) { r.AddCookie(&http.Cookie{Name: csrfCookie, Value: "csrf"}) }, http.StatusForbidden},
+		{"cookie with token", func(r *http.Request) {
+			r.AddCookie(&http.Cookie{Name: csrfCookie, Value: "csrf"})
+			r.Header.Set(csrfHeader, "csrf")
+		}, http.StatusOK},
+	}
+	for _, tt := range unsafe {
+		r := httptest.NewRequest(http.MethodPost, "/upload", nil)
+		r.SetBasicAuth("admin", "hunter2")
+		tt.header(r)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != tt.want {
+			t.Errorf("basic post %s: want: %v, got %v", tt.name, tt.want, w.Code)
+		}
+		if w.Code == http.StatusForbidden && (w.Header().Get("WWW-Authenticate") != "" || !strings.Contains(w.Body.String(), "csrf token is not valid")) {
+			t.Errorf("basic post %s: want csrf error without a password prompt, got %v %s", tt.name, w.Header(), w.Body)
+		}
+	}
+}
+
+func TestAPILoginRequiredMiddleware(t *testing.T) {
+	secret, hash, err := NewTokenSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &ServerConfig{}
+	server := &Server{
+		config: config,
+		store: &tokenStore{
+			hash:  hash,
+			token: APIToken{ID: uuid.Must(uuid.NewV4()), Name: "ci", Scope: "read"},
+		},
+	}
+
+	r := mux.NewRouter()
+	api := r.PathPrefix("/api/v1").Subrouter()
+	api.Use(APILoginRequiredMiddleware(server))
+	api.Handle("/torrents/{id}/peers", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("peers"))
+	}))
+
+	get := func(auth string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/torrents/1/peers", nil)
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := get(""); w.Code != http.StatusOK {
+		t.Errorf("want open api without login, got %v", w.Code)
+	}
+
+	config.RequireLogin = true
+	w := get("")
+	if w.Code != http.StatusUnauthorized || !strings.Contains(w.Body.String(), "unauthorized") {
+		t.Errorf("want 401 without credentials, got %v %s", w.Code, w.Body)
+	}
+	if w := get("Bearer trk_nope"); w.Code != http.StatusUnauthorized {
+		t.Errorf("want 401 with unknown token, got %v", w.Code)
+	}
+	if w := get("Bearer " + secret); w.Code != http.StatusOK || w.Body.String() != "peers" {
+		t.Errorf("want peers with token, got %v %s", w.Code, w.Body)
+	}
+}
@@ -0,0 +1,74 @@
+package tracker
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const requestIDHeader = "X-Request-ID"
+
+// Request IDs accepted from clients and proxies.
+var requestIDRe = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+type requestIDKey struct{}
+
+// Returns the request ID assigned by AccessLogMiddleware, or an empty string.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// Middleware that assigns every request an ID and writes one access log line per request.
+// A valid X-Request-ID header is used as the ID, otherwise a new one is generated.
+// The ID is sent back in X-Request-ID and the request context carries a logger with the ID,
+// so handlers and the store log with zerolog.Ctx or log.Ctx.
+func AccessLogMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		id := r.Header.Get(requestIDHeader)
+		if !requestIDRe.MatchString(id) {
+			id = uuid.Must(uuid.NewV4()).String()
+		}
+		w.Header().Set(requestIDHeader, id)
+
+		logger := log.With().Str("request_id", id).Logger()
+		ctx := context.WithValue(r.Context(), requestIDKey{}, id)
+		ctx = logger.WithContext(ctx)
+
+		rw := &recordingWriter{ResponseWriter: w}
+		next.ServeHTTP(rw, r.WithContext(ctx))
+
+		status := rw.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		event := logger.Info()
+		if status >= http.StatusInternalServerError {
+			event = logger.Error()
+		}
+		ip, _ := remoteIP(r)
+		event.
+			Str("source", "http_access").
+			Str("method", r.Method).
+			Str("route", routeTemplate(r)).
+			Str("path", r.URL.Path).
+			Int("status", status).
+			Dur("latency", time.Since(start)).
+			Str("ip", ip)
+		if reason := failureReason(rw.body.Bytes()); reason != "" {
+			event.Str("failure_reason", reason)
+		}
+		if sc := trace.SpanContextFromContext(r.Context()); sc.IsValid() {
+			event.Str("trace_id", sc.TraceID().String())
+		}
+		event.Msg("request")
+	})
+}
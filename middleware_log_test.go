@@ -0,0 +1,98 @@
+package tracker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+func TestAccessLogMiddleware(t *testing.T) {
+	var buf bytes.Buffer
+	prev := log.Logger
+	log.Logger = zerolog.New(&buf)
+	t.Cleanup(func() { log.Logger = prev })
+
+	rs := &recordingStore{}
+	wb := NewWriteBehindStore(rs, WriteBehindConfig{FlushInterval: time.Hour, FlushSize: 100, QueueSize: 100})
+
+	r := mux.NewRouter()
+	r.Use(AccessLogMiddleware)
+	r.HandleFunc("/{passkey}/announce", func(w http.ResponseWriter, r *http.Request) {
+		log.Ctx(r.Context()).Info().Msg("from handler")
+		err := wb.Log(r.Context(), AnnounceRequest{PeerID: []byte("-TR3000-dybw6lsnsc17"), IP: "127.0.0.1"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		replyBencode(w, ErrorResponse{FailureReason: "passkey is not valid"}, http.StatusForbidden)
+	})
+
+	for _, tc := range []struct {
+		header   string
+		accepted bool
+	}{
+		{"", false},
+		{"edge-1234.abc_DEF", true},
+		{"not valid", false},
+		{strings.Repeat("a", 65), false},
+	} {
+		buf.Reset()
+		req := httptest.NewRequest(http.MethodGet, "/secret/announce", nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		if tc.header != "" {
+			req.Header.Set("X-Request-ID", tc.header)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		id := w.Header().Get("X-Request-ID")
+		if tc.accepted && id != tc.header {
+			t.Errorf("%q: want request id to be kept, got %q", tc.header, id)
+		}
+		if !tc.accepted && (id == tc.header || !requestIDRe.MatchString(id)) {
+			t.Errorf("%q: want a generated request id, got %q", tc.header, id)
+		}
+
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		if len(lines) != 2 {
+			t.Fatalf("want handler and access log lines, got %q", buf.String())
+		}
+		var handler, access map[string]any
+		json.Unmarshal([]byte(lines[0]), &handler)
+		json.Unmarshal([]byte(lines[1]), &access)
+
+		if handler["request_id"] != id || access["request_id"] != id {
+			t.Errorf("want request id %q on every line, got %q", id, buf.String())
+		}
+		for k, want := range map[string]any{
+			"route":          "/{passkey}/announce",
+			"path":           "/secret/announce",
+			"status":         float64(http.StatusForbidden),
+			"ip":             "10.0.0.1",
+			"failure_reason": "passkey is not valid",
+		} {
+			if access[k] != want {
+				t.Errorf("want %s %v, got %v", k, want, access[k])
+			}
+		}
+		if _, ok := access["latency"]; !ok {
+			t.Errorf("want latency in access log")
+		}
+	}
+
+	err := wb.Close(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rs.logs) != 4 || rs.logs[1].RequestID != "edge-1234.abc_DEF" {
+		t.Errorf("want request ids in the announce log, got %+v", rs.logs)
+	}
+}
@@ -0,0 +1,81 @@
+package tracker
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofrs/uuid"
+	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v5"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/salimnassim/tracker/metric"
+)
+
+// Returns the number of observations of a histogram.
+func sampleCount(t *testing.T, o prometheus.Observer) uint64 {
+	t.Helper()
+	var m dto.Metric
+	err := o.(prometheus.Metric).Write(&m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m.GetHistogram().GetSampleCount()
+}
+
+func TestMetricsMiddleware(t *testing.T) {
+	r := mux.NewRouter()
+	r.Use(MetricsMiddleware)
+	r.HandleFunc("/metrics-test/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+
+	observer := metric.TrackerHTTPSeconds.WithLabelValues("/metrics-test/{id}", http.MethodGet, "418")
+	before := sampleCount(t, observer)
+
+	for _, id := range []string{"a", "b"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/metrics-test/"+id, nil))
+	}
+
+	if got := sampleCount(t, observer) - before; got != 2 {
+		t.Errorf("want 2 observations of the route, got %d", got)
+	}
+}
+
+// Fails TorrentByID for one id and finds no rows for the others.
+type failingStore struct {
+	TorrentStorable
+	failing uuid.UUID
+}
+
+func (s *failingStore) TorrentByID(ctx context.Context, torrentID uuid.UUID) (Torrent, error) {
+	if torrentID == s.failing {
+		return Torrent{}, errors.New("connection refused")
+	}
+	return Torrent{}, pgx.ErrNoRows
+}
+
+func TestInstrumentedStore(t *testing.T) {
+	failing := uuid.Must(uuid.NewV4())
+	store := NewInstrumentedStore(&failingStore{failing: failing})
+
+	ok := metric.TrackerStoreSeconds.WithLabelValues("TorrentByID", "ok")
+	failed := metric.TrackerStoreSeconds.WithLabelValues("TorrentByID", "error")
+	beforeOK, beforeFailed := sampleCount(t, ok), sampleCount(t, failed)
+
+	_, err := store.TorrentByID(context.Background(), uuid.Must(uuid.NewV4()))
+	if !errors.Is(err, pgx.ErrNoRows) {
+		t.Fatalf("want no rows, got %v", err)
+	}
+	_, err = store.TorrentByID(context.Background(), failing)
+	if err == nil {
+		t.Fatal("want error")
+	}
+
+	if sampleCount(t, ok)-beforeOK != 1 || sampleCount(t, failed)-beforeFailed != 1 {
+		t.Errorf("want one ok and one failed call recorded")
+	}
+}
@@ -0,0 +1,89 @@
+package tracker
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/cristalhq/bencode"
+	"github.com/rs/zerolog/log"
+)
+
+// Recorded tracker request, written as one JSON line per request.
+type Record struct {
+	Time          time.Time `json:"time"`
+	Path          string    `json:"path"`
+	Query         string    `json:"query"`
+	IP            string    `json:"ip"`
+	Status        int       `json:"status"`
+	FailureReason string    `json:"failure_reason,omitempty"`
+}
+
+// Captures the status and the body of failed responses.
+type recordingWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rw *recordingWriter) WriteHeader(statusCode int) {
+	rw.status = statusCode
+	rw.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (rw *recordingWriter) Write(b []byte) (int, error) {
+	if rw.status == 0 {
+		rw.status = http.StatusOK
+	}
+	if rw.status != http.StatusOK {
+		rw.body.Write(b)
+	}
+	return rw.ResponseWriter.Write(b)
+}
+
+// Returns the failure reason of a bencoded error response.
+func failureReason(body []byte) string {
+	var failure map[string]any
+	err := bencode.Unmarshal(body, &failure)
+	if err != nil {
+		return ""
+	}
+	reason, _ := failure["failure reason"].([]byte)
+	return string(reason)
+}
+
+// Middleware that writes every request and its response status to w as JSONL.
+func RecorderMiddleware(w io.Writer) func(http.Handler) http.Handler {
+	mu := &sync.Mutex{}
+	enc := json.NewEncoder(w)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			record := Record{
+				Time:  time.Now(),
+				Path:  r.URL.Path,
+				Query: r.URL.RawQuery,
+			}
+			record.IP, _ = remoteIP(r)
+
+			rw := &recordingWriter{ResponseWriter: w}
+			next.ServeHTTP(rw, r)
+
+			record.Status = rw.status
+			if record.Status == 0 {
+				record.Status = http.StatusOK
+			}
+			record.FailureReason = failureReason(rw.body.Bytes())
+
+			mu.Lock()
+			err := enc.Encode(record)
+			mu.Unlock()
+			if err != nil {
+				log.Error().Err(err).Str("source", "recorder").Msg("cant write record")
+			}
+		})
+	}
+}
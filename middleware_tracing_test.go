@@ -0,0 +1,70 @@
+package tracker
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofrs/uuid"
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTracingMiddleware(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	prevProvider, prevPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prevProvider)
+		otel.SetTextMapPropagator(prevPropagator)
+	})
+
+	failing := uuid.Must(uuid.NewV4())
+	store := NewInstrumentedStore(&failingStore{failing: failing})
+
+	r := mux.NewRouter()
+	r.Use(TracingMiddleware)
+	r.HandleFunc("/torrent/{id}", func(w http.ResponseWriter, r *http.Request) {
+		_, err := store.TorrentByID(r.Context(), uuid.FromStringOrNil(mux.Vars(r)["id"]))
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/torrent/"+failing.String(), nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("want 2 spans, got %d", len(spans))
+	}
+	storeSpan, serverSpan := spans[0], spans[1]
+
+	if serverSpan.Name() != "GET /torrent/{id}" || serverSpan.Parent().SpanID().String() != "00f067aa0ba902b7" {
+		t.Errorf("want server span continuing the trace, got %s with parent %s", serverSpan.Name(), serverSpan.Parent().SpanID())
+	}
+	if serverSpan.SpanContext().TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("want trace id from traceparent, got %s", serverSpan.SpanContext().TraceID())
+	}
+	if storeSpan.Name() != "store.TorrentByID" || storeSpan.Parent().SpanID() != serverSpan.SpanContext().SpanID() {
+		t.Errorf("want store span in server span, got %s", storeSpan.Name())
+	}
+	want := attribute.String("tracker.torrent_id", failing.String())
+	found := false
+	for _, attr := range storeSpan.Attributes() {
+		found = found || attr == want
+	}
+	if !found {
+		t.Errorf("want %v in store span attributes %v", want, storeSpan.Attributes())
+	}
+	if storeSpan.Status().Description != "connection refused" {
+		t.Errorf("want failed store span, got status %+v", storeSpan.Status())
+	}
+}
@@ -0,0 +1,4 @@
+DROP TRIGGER IF EXISTS peers_count ON public.peers;
+DROP FUNCTION IF EXISTS public.peers_count_trigger();
+ALTER TABLE public.torrents DROP COLUMN IF EXISTS seeders;
+ALTER TABLE public.torrents DROP COLUMN IF EXISTS leechers;
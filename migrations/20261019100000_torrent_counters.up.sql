@@ -0,0 +1,35 @@
+ALTER TABLE public.torrents ADD COLUMN seeders integer NOT NULL DEFAULT 0;
+ALTER TABLE public.torrents ADD COLUMN leechers integer NOT NULL DEFAULT 0;
+
+UPDATE public.torrents t SET
+    seeders = (SELECT count(*) FROM public.peers p WHERE p.torrent_id = t.id AND p."left" = 0),
+    leechers = (SELECT count(*) FROM public.peers p WHERE p.torrent_id = t.id AND p."left" != 0);
+
+-- Keeps torrents.seeders and torrents.leechers in sync with peers.
+CREATE OR REPLACE FUNCTION public.peers_count_trigger() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'UPDATE' AND OLD.torrent_id = NEW.torrent_id AND (OLD."left" = 0) = (NEW."left" = 0) THEN
+        RETURN NULL;
+    END IF;
+
+    IF TG_OP IN ('UPDATE', 'DELETE') THEN
+        UPDATE public.torrents SET
+            seeders = seeders - (OLD."left" = 0)::integer,
+            leechers = leechers - (OLD."left" != 0)::integer
+        WHERE id = OLD.torrent_id;
+    END IF;
+
+    IF TG_OP IN ('UPDATE', 'INSERT') THEN
+        UPDATE public.torrents SET
+            seeders = seeders + (NEW."left" = 0)::integer,
+            leechers = leechers + (NEW."left" != 0)::integer
+        WHERE id = NEW.torrent_id;
+    END IF;
+
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER peers_count
+    AFTER INSERT OR DELETE OR UPDATE OF torrent_id, "left" ON public.peers
+    FOR EACH ROW EXECUTE FUNCTION public.peers_count_trigger();
@@ -0,0 +1,24 @@
+ALTER TABLE public.announce_log RENAME TO announce_log_partitioned;
+
+CREATE TABLE IF NOT EXISTS public.announce_log
+(
+    id uuid NOT NULL,
+    info_hash bytea NOT NULL,
+    peer_id bytea NOT NULL,
+    event text COLLATE pg_catalog."default" NOT NULL,
+    ip inet NOT NULL,
+    port integer NOT NULL,
+    key text COLLATE pg_catalog."default" NOT NULL,
+    uploaded bigint NOT NULL,
+    downloaded bigint NOT NULL,
+    "left" bigint NOT NULL,
+    created_at timestamp with time zone NOT NULL,
+    CONSTRAINT announce_log_unpartitioned_pkey PRIMARY KEY (id)
+);
+
+INSERT INTO public.announce_log SELECT * FROM public.announce_log_partitioned;
+DROP TABLE public.announce_log_partitioned;
+ALTER TABLE public.announce_log RENAME CONSTRAINT announce_log_unpartitioned_pkey TO announce_log_pkey;
+
+ALTER TABLE IF EXISTS public.announce_log
+    OWNER to tracker;
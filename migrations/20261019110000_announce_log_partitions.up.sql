@@ -0,0 +1,32 @@
+ALTER TABLE public.announce_log RENAME TO announce_log_old;
+ALTER TABLE public.announce_log_old RENAME CONSTRAINT announce_log_pkey TO announce_log_old_pkey;
+
+-- Daily partitions named announce_log_pYYYYMMDD are created and dropped by the tracker.
+CREATE TABLE IF NOT EXISTS public.announce_log
+(
+    id uuid NOT NULL,
+    info_hash bytea NOT NULL,
+    peer_id bytea NOT NULL,
+    event text COLLATE pg_catalog."default" NOT NULL,
+    ip inet NOT NULL,
+    port integer NOT NULL,
+    key text COLLATE pg_catalog."default" NOT NULL,
+    uploaded bigint NOT NULL,
+    downloaded bigint NOT NULL,
+    "left" bigint NOT NULL,
+    created_at timestamp with time zone NOT NULL,
+    CONSTRAINT announce_log_pkey PRIMARY KEY (id, created_at)
+) PARTITION BY RANGE (created_at);
+
+-- Catches rows without a daily partition, including the rows logged before partitioning.
+CREATE TABLE IF NOT EXISTS public.announce_log_default PARTITION OF public.announce_log DEFAULT;
+
+CREATE INDEX IF NOT EXISTS announce_log_info_hash_created_at_idx ON public.announce_log (info_hash, created_at);
+CREATE INDEX IF NOT EXISTS announce_log_peer_id_created_at_idx ON public.announce_log (peer_id, created_at);
+CREATE INDEX IF NOT EXISTS announce_log_ip_created_at_idx ON public.announce_log (ip, created_at);
+
+INSERT INTO public.announce_log SELECT * FROM public.announce_log_old;
+DROP TABLE public.announce_log_old;
+
+ALTER TABLE IF EXISTS public.announce_log
+    OWNER to tracker;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS public.audit_log;
+ALTER TABLE public.torrents DROP COLUMN IF EXISTS flags;
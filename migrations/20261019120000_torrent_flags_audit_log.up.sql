@@ -0,0 +1,17 @@
+ALTER TABLE public.torrents ADD COLUMN flags text[] NOT NULL DEFAULT '{}';
+
+CREATE TABLE IF NOT EXISTS public.audit_log
+(
+    id uuid NOT NULL,
+    actor text COLLATE pg_catalog."default" NOT NULL,
+    action text COLLATE pg_catalog."default" NOT NULL,
+    target text COLLATE pg_catalog."default" NOT NULL,
+    details jsonb NOT NULL DEFAULT '{}',
+    created_at timestamp with time zone NOT NULL,
+    CONSTRAINT audit_log_pkey PRIMARY KEY (id)
+);
+
+CREATE INDEX IF NOT EXISTS audit_log_created_at_idx ON public.audit_log (created_at);
+
+ALTER TABLE IF EXISTS public.audit_log
+    OWNER to tracker;
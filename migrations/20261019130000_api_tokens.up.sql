@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS public.api_tokens
+(
+    id uuid NOT NULL,
+    name text COLLATE pg_catalog."default" NOT NULL,
+    hash bytea NOT NULL,
+    scope text COLLATE pg_catalog."default" NOT NULL,
+    created_at timestamp with time zone NOT NULL,
+    last_used_at timestamp with time zone,
+    revoked_at timestamp with time zone,
+    CONSTRAINT api_tokens_pkey PRIMARY KEY (id),
+    CONSTRAINT api_tokens_hash_key UNIQUE (hash)
+);
+
+ALTER TABLE IF EXISTS public.api_tokens
+    OWNER to tracker;
@@ -0,0 +1,5 @@
+ALTER TABLE IF EXISTS public.users
+    ADD COLUMN IF NOT EXISTS subject text COLLATE pg_catalog."default";
+
+ALTER TABLE IF EXISTS public.users
+    ADD CONSTRAINT users_subject_key UNIQUE (subject);
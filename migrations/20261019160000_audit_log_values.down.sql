@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS public.audit_log_target_created_at_idx;
+DROP INDEX IF EXISTS public.audit_log_action_created_at_idx;
+DROP INDEX IF EXISTS public.audit_log_actor_created_at_idx;
+
+ALTER TABLE IF EXISTS public.audit_log
+    DROP COLUMN IF EXISTS new_value;
+
+ALTER TABLE IF EXISTS public.audit_log
+    DROP COLUMN IF EXISTS old_value;
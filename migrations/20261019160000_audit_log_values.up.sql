@@ -0,0 +1,9 @@
+ALTER TABLE IF EXISTS public.audit_log
+    ADD COLUMN IF NOT EXISTS old_value jsonb;
+
+ALTER TABLE IF EXISTS public.audit_log
+    ADD COLUMN IF NOT EXISTS new_value jsonb;
+
+CREATE INDEX IF NOT EXISTS audit_log_actor_created_at_idx ON public.audit_log (actor, created_at);
+CREATE INDEX IF NOT EXISTS audit_log_action_created_at_idx ON public.audit_log (action, created_at);
+CREATE INDEX IF NOT EXISTS audit_log_target_created_at_idx ON public.audit_log (target, created_at);
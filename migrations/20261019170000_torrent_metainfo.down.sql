@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS public.torrent_metainfo;
+
+ALTER TABLE public.torrents DROP COLUMN IF EXISTS size;
+ALTER TABLE public.torrents DROP COLUMN IF EXISTS name;
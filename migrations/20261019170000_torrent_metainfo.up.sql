@@ -0,0 +1,22 @@
+ALTER TABLE public.torrents ADD COLUMN name text NOT NULL DEFAULT '';
+ALTER TABLE public.torrents ADD COLUMN size bigint NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS public.torrent_metainfo
+(
+    torrent_id uuid NOT NULL,
+    piece_length bigint NOT NULL,
+    pieces integer NOT NULL,
+    private boolean NOT NULL,
+    files jsonb NOT NULL,
+    info bytea NOT NULL,
+    uploaded_by text COLLATE pg_catalog."default" NOT NULL,
+    created_at timestamp with time zone NOT NULL,
+    CONSTRAINT torrent_metainfo_pkey PRIMARY KEY (torrent_id),
+    CONSTRAINT torrent_metainfo_torrent_id_fkey FOREIGN KEY (torrent_id)
+        REFERENCES public.torrents (id) MATCH SIMPLE
+        ON UPDATE NO ACTION
+        ON DELETE CASCADE
+);
+
+ALTER TABLE IF EXISTS public.torrent_metainfo
+    OWNER to tracker;
@@ -0,0 +1,5 @@
+ALTER TABLE IF EXISTS public.users
+    ADD COLUMN IF NOT EXISTS passkey text COLLATE pg_catalog."default" NOT NULL DEFAULT replace(gen_random_uuid()::text, '-', '');
+
+ALTER TABLE IF EXISTS public.users
+    ADD CONSTRAINT users_passkey_key UNIQUE (passkey);
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS public.torrents_category_idx;
+DROP INDEX IF EXISTS public.torrents_tags_idx;
+DROP INDEX IF EXISTS public.torrents_search_idx;
+
+ALTER TABLE public.torrents DROP COLUMN IF EXISTS search;
+ALTER TABLE public.torrents DROP COLUMN IF EXISTS tags;
+ALTER TABLE public.torrents DROP COLUMN IF EXISTS category;
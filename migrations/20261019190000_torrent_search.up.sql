@@ -0,0 +1,8 @@
+ALTER TABLE public.torrents ADD COLUMN category text NOT NULL DEFAULT '';
+ALTER TABLE public.torrents ADD COLUMN tags text[] NOT NULL DEFAULT '{}';
+ALTER TABLE public.torrents ADD COLUMN search tsvector
+    GENERATED ALWAYS AS (to_tsvector('simple', translate(name, '._-', '   '))) STORED;
+
+CREATE INDEX IF NOT EXISTS torrents_search_idx ON public.torrents USING gin (search);
+CREATE INDEX IF NOT EXISTS torrents_tags_idx ON public.torrents USING gin (tags);
+CREATE INDEX IF NOT EXISTS torrents_category_idx ON public.torrents (category);
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS public.torrents_completed_id_idx;
+DROP INDEX IF EXISTS public.torrents_leechers_id_idx;
+DROP INDEX IF EXISTS public.torrents_seeders_id_idx;
+DROP INDEX IF EXISTS public.torrents_created_at_id_idx;
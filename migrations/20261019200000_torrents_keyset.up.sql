@@ -0,0 +1,4 @@
+CREATE INDEX IF NOT EXISTS torrents_created_at_id_idx ON public.torrents (created_at, id);
+CREATE INDEX IF NOT EXISTS torrents_seeders_id_idx ON public.torrents (seeders, id);
+CREATE INDEX IF NOT EXISTS torrents_leechers_id_idx ON public.torrents (leechers, id);
+CREATE INDEX IF NOT EXISTS torrents_completed_id_idx ON public.torrents (completed, id);
@@ -0,0 +1,20 @@
+-- Seeders, leechers and completed of torrents with peers, taken by the tracker at a fixed interval.
+-- Raw snapshots are rolled up into hourly and daily averages.
+CREATE TABLE IF NOT EXISTS public.swarm_snapshots
+(
+    torrent_id uuid NOT NULL,
+    resolution text COLLATE pg_catalog."default" NOT NULL,
+    at timestamp with time zone NOT NULL,
+    seeders integer NOT NULL,
+    leechers integer NOT NULL,
+    completed bigint NOT NULL,
+    CONSTRAINT swarm_snapshots_pkey PRIMARY KEY (torrent_id, resolution, at),
+    CONSTRAINT swarm_snapshots_torrent_id_fkey FOREIGN KEY (torrent_id)
+        REFERENCES public.torrents (id) ON DELETE CASCADE,
+    CONSTRAINT swarm_snapshots_resolution_check CHECK (resolution IN ('raw', 'hour', 'day'))
+);
+
+CREATE INDEX IF NOT EXISTS swarm_snapshots_resolution_at_idx ON public.swarm_snapshots (resolution, at);
+
+ALTER TABLE IF EXISTS public.swarm_snapshots
+    OWNER to tracker;
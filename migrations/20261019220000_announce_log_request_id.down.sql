@@ -0,0 +1 @@
+ALTER TABLE public.announce_log DROP COLUMN IF EXISTS request_id;
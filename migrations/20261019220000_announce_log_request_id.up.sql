@@ -0,0 +1 @@
+ALTER TABLE public.announce_log ADD COLUMN IF NOT EXISTS request_id text NOT NULL DEFAULT '';
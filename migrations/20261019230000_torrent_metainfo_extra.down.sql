@@ -0,0 +1 @@
+ALTER TABLE public.torrent_metainfo DROP COLUMN IF EXISTS extra;
@@ -0,0 +1,2 @@
+-- Top level keys of uploaded .torrent files other than info and the announce URLs, e.g. url-list.
+ALTER TABLE public.torrent_metainfo ADD COLUMN IF NOT EXISTS extra bytea NOT NULL DEFAULT '';
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS public.swarm_snapshot_runs;
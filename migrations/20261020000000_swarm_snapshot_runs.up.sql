@@ -0,0 +1,16 @@
+-- Times snapshots were taken or rolled up at, including runs where no torrent had peers.
+-- Rollups divide by the number of runs, so empty runs count as zero peers.
+CREATE TABLE IF NOT EXISTS public.swarm_snapshot_runs
+(
+    resolution text COLLATE pg_catalog."default" NOT NULL,
+    at timestamp with time zone NOT NULL,
+    CONSTRAINT swarm_snapshot_runs_pkey PRIMARY KEY (resolution, at),
+    CONSTRAINT swarm_snapshot_runs_resolution_check CHECK (resolution IN ('raw', 'hour', 'day'))
+);
+
+INSERT INTO public.swarm_snapshot_runs (resolution, at)
+SELECT DISTINCT resolution, at FROM public.swarm_snapshots
+ON CONFLICT DO NOTHING;
+
+ALTER TABLE IF EXISTS public.swarm_snapshot_runs
+    OWNER to tracker;
@@ -0,0 +1,35 @@
+// Package migrations embeds the golang-migrate schema migrations.
+package migrations
+
+import (
+	"embed"
+	"io/fs"
+	"regexp"
+	"strconv"
+)
+
+//go:embed *.sql
+var FS embed.FS
+
+var upRe = regexp.MustCompile(`^(\d+)_.+\.up\.sql$`)
+
+// Returns the version of the newest up migration, e.g. 20261019220000.
+func Latest() int64 {
+	entries, err := fs.ReadDir(FS, ".")
+	if err != nil {
+		return 0
+	}
+
+	var latest int64
+	for _, e := range entries {
+		m := upRe.FindStringSubmatch(e.Name())
+		if m == nil {
+			continue
+		}
+		v, err := strconv.ParseInt(m[1], 10, 64)
+		if err == nil && v > latest {
+			latest = v
+		}
+	}
+	return latest
+}
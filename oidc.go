@@ -0,0 +1,249 @@
+package tracker
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+const oidcCookie = "tracker_oidc"
+
+var errNoGroup = errors.New("user is in no mapped group")
+
+type OIDCConfig struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// Callback URL registered at the identity provider, e.g.
+	// https://tracker.example.com/login/oidc/callback
+	RedirectURL string
+	// ID token claim with the groups of the user.
+	GroupsClaim string
+	// Scope granted to members of each group. Users in no mapped group can't log in.
+	GroupScopes map[string]Scope
+}
+
+// Parses group to scope mappings like "ops=admin,mods=moderate,staff=read".
+func ParseGroupScopes(s string) (map[string]Scope, error) {
+	scopes := map[string]Scope{}
+	for _, pair := range strings.Split(s, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		group, scope, found := strings.Cut(pair, "=")
+		if !found {
+			return nil, fmt.Errorf("group mapping %q is not valid", pair)
+		}
+		parsed, err := ParseScope(strings.TrimSpace(scope))
+		if err != nil {
+			return nil, err
+		}
+		scopes[strings.TrimSpace(group)] = parsed
+	}
+	return scopes, nil
+}
+
+// Returns the highest scope of groups. ok is false if no group is mapped.
+func (c *OIDCConfig) scope(groups []string) (scope Scope, ok bool) {
+	for _, group := range groups {
+		if s, found := c.GroupScopes[group]; found && s > scope {
+			scope, ok = s, true
+		}
+	}
+	return scope, ok
+}
+
+// Client of the identity provider.
+// The provider is discovered on first use so the tracker starts while it is down.
+type oidcClient struct {
+	config OIDCConfig
+
+	mu       sync.Mutex
+	provider *oidc.Provider
+}
+
+func newOIDCClient(config OIDCConfig) *oidcClient {
+	return &oidcClient{config: config}
+}
+
+func (c *oidcClient) setup(ctx context.Context) (*oidc.Provider, *oauth2.Config, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.provider == nil {
+		provider, err := oidc.NewProvider(ctx, c.config.Issuer)
+		if err != nil {
+			return nil, nil, err
+		}
+		c.provider = provider
+	}
+
+	return c.provider, &oauth2.Config{
+		ClientID:     c.config.ClientID,
+		ClientSecret: c.config.ClientSecret,
+		RedirectURL:  c.config.RedirectURL,
+		Endpoint:     c.provider.Endpoint(),
+		Scopes:       []string{oidc.ScopeOpenID, "profile", "email"},
+	}, nil
+}
+
+// State of an authorization request, kept in a cookie until the callback.
+type oidcState struct {
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+	Next     string `json:"next"`
+}
+
+// Claims of the ID token used to create the user.
+type oidcClaims struct {
+	Nonce             string `json:"nonce"`
+	PreferredUsername string `json:"preferred_username"`
+	Email             string `json:"email"`
+}
+
+// Returns the username of the claims.
+func (c *oidcClaims) username(subject string) string {
+	if c.PreferredUsername != "" {
+		return c.PreferredUsername
+	}
+	if c.Email != "" {
+		return c.Email
+	}
+	return subject
+}
+
+// Reads a list of strings claim, which some providers send as a single string.
+func groupsClaim(claims map[string]any, name string) []string {
+	switch v := claims[name].(type) {
+	case string:
+		return []string{v}
+	case []any:
+		groups := make([]string, 0, len(v))
+		for _, g := range v {
+			if s, ok := g.(string); ok {
+				groups = append(groups, s)
+			}
+		}
+		return groups
+	}
+	return nil
+}
+
+// Starts an authorization code flow and returns the URL of the identity provider.
+func (c *oidcClient) authorize(ctx context.Context, w http.ResponseWriter, next string, secure bool) (string, error) {
+	_, config, err := c.setup(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	state, err := randomString()
+	if err != nil {
+		return "", err
+	}
+	nonce, err := randomString()
+	if err != nil {
+		return "", err
+	}
+
+	s := oidcState{
+		State:    state,
+		Nonce:    nonce,
+		Verifier: oauth2.GenerateVerifier(),
+		Next:     safeRedirect(next),
+	}
+	value, err := json.Marshal(s)
+	if err != nil {
+		return "", err
+	}
+
+	// lax so the cookie is sent on the redirect back from the provider
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcCookie,
+		Value:    base64.RawURLEncoding.EncodeToString(value),
+		Path:     "/login/oidc",
+		MaxAge:   int((10 * time.Minute).Seconds()),
+		HttpOnly: true,
+		Secure:   secure,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	return config.AuthCodeURL(s.State, oidc.Nonce(s.Nonce), oauth2.S256ChallengeOption(s.Verifier)), nil
+}
+
+// Completes an authorization code flow. Returns the subject, username and
+// scope of the user and the path to redirect to.
+func (c *oidcClient) callback(w http.ResponseWriter, r *http.Request) (subject string, username string, scope Scope, next string, err error) {
+	ctx := r.Context()
+
+	cookie, err := r.Cookie(oidcCookie)
+	if err != nil {
+		return "", "", 0, "", fmt.Errorf("no login in progress")
+	}
+	http.SetCookie(w, &http.Cookie{Name: oidcCookie, Path: "/login/oidc", MaxAge: -1})
+
+	value, err := base64.RawURLEncoding.DecodeString(cookie.Value)
+	if err != nil {
+		return "", "", 0, "", err
+	}
+	var s oidcState
+	err = json.Unmarshal(value, &s)
+	if err != nil {
+		return "", "", 0, "", err
+	}
+
+	if r.URL.Query().Get("state") != s.State {
+		return "", "", 0, "", fmt.Errorf("state does not match")
+	}
+	if e := r.URL.Query().Get("error"); e != "" {
+		return "", "", 0, "", fmt.Errorf("identity provider returned %s", e)
+	}
+
+	provider, config, err := c.setup(ctx)
+	if err != nil {
+		return "", "", 0, "", err
+	}
+
+	token, err := config.Exchange(ctx, r.URL.Query().Get("code"), oauth2.VerifierOption(s.Verifier))
+	if err != nil {
+		return "", "", 0, "", err
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return "", "", 0, "", fmt.Errorf("token response has no id token")
+	}
+
+	idToken, err := provider.Verifier(&oidc.Config{ClientID: c.config.ClientID}).Verify(ctx, rawIDToken)
+	if err != nil {
+		return "", "", 0, "", err
+	}
+
+	var claims oidcClaims
+	var all map[string]any
+	err = idToken.Claims(&claims)
+	if err == nil {
+		err = idToken.Claims(&all)
+	}
+	if err != nil {
+		return "", "", 0, "", err
+	}
+	if claims.Nonce != s.Nonce {
+		return "", "", 0, "", fmt.Errorf("nonce does not match")
+	}
+
+	scope, ok = c.config.scope(groupsClaim(all, c.config.GroupsClaim))
+	if !ok {
+		return "", "", 0, "", errNoGroup
+	}
+
+	return idToken.Issuer + " " + idToken.Subject, claims.username(idToken.Subject), scope, s.Next, nil
+}
@@ -0,0 +1,257 @@
+package tracker
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v3"
+	"github.com/gofrs/uuid"
+)
+
+// Stand-in identity provider that signs in a fixed user without asking.
+type testIdP struct {
+	*httptest.Server
+	key    *rsa.PrivateKey
+	groups []string
+
+	mu    sync.Mutex
+	codes map[string]url.Values
+}
+
+func newTestIdP(t *testing.T, groups []string) *testIdP {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idp := &testIdP{key: key, groups: groups, codes: map[string]url.Values{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"issuer":                                idp.URL,
+			"authorization_endpoint":                idp.URL + "/authorize",
+			"token_endpoint":                        idp.URL + "/token",
+			"jwks_uri":                              idp.URL + "/jwks",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{
+			{Key: &key.PublicKey, KeyID: "test", Algorithm: "RS256", Use: "sig"},
+		}})
+	})
+	mux.HandleFunc("/authorize", func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		code := uuid.Must(uuid.NewV4()).String()
+		idp.mu.Lock()
+		idp.codes[code] = query
+		idp.mu.Unlock()
+
+		redirect, _ := url.Parse(query.Get("redirect_uri"))
+		redirect.RawQuery = url.Values{"code": {code}, "state": {query.Get("state")}}.Encode()
+		http.Redirect(w, r, redirect.String(), http.StatusFound)
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		idp.mu.Lock()
+		authorize, ok := idp.codes[r.PostFormValue("code")]
+		delete(idp.codes, r.PostFormValue("code"))
+		idp.mu.Unlock()
+
+		clientID, clientSecret, _ := r.BasicAuth()
+		challenge := sha256.Sum256([]byte(r.PostFormValue("code_verifier")))
+		if !ok || clientID != "tracker" || clientSecret != "secret" ||
+			authorize.Get("code_challenge") != base64.RawURLEncoding.EncodeToString(challenge[:]) {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"access_token": "access",
+			"token_type":   "Bearer",
+			"expires_in":   3600,
+			"id_token":     idp.idToken(t, authorize.Get("nonce")),
+		})
+	})
+	idp.Server = httptest.NewServer(mux)
+	t.Cleanup(idp.Close)
+	return idp
+}
+
+func (idp *testIdP) idToken(t *testing.T, nonce string) string {
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.RS256, Key: idp.key},
+		(&jose.SignerOptions{}).WithType("JWT").WithHeader("kid", "test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, _ := json.Marshal(map[string]any{
+		"iss":                idp.URL,
+		"sub":                "1234",
+		"aud":                "tracker",
+		"exp":                time.Now().Add(time.Hour).Unix(),
+		"iat":                time.Now().Unix(),
+		"nonce":              nonce,
+		"preferred_username": "alice",
+		"groups":             idp.groups,
+	})
+	token, err := signer.Sign(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := token.CompactSerialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+func (s *sessionStore) UpsertSubjectUser(ctx context.Context, subject string, username string, scope Scope) (User, error) {
+	// usernames are unique like in the users table
+	if s.user.Username == username && (s.user.Subject == nil || *s.user.Subject != subject) {
+		return User{}, ErrUsernameTaken
+	}
+	s.user = User{ID: uuid.Must(uuid.NewV4()), Username: username, Scope: scope.String(), Subject: &subject}
+	return s.user, nil
+}
+
+// Runs the authorization code flow against idp and returns the callback response.
+func oidcLogin(t *testing.T, server *Server, idp *testIdP, tamper func(callback *url.URL)) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	OIDCLoginHandler(server).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/login/oidc?next=/torrent/1", nil))
+	if w.Code != http.StatusFound {
+		t.Fatalf("login: want: %v, got %v: %s", http.StatusFound, w.Code, w.Body.String())
+	}
+	cookies := w.Result().Cookies()
+
+	client := &http.Client{CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	res, err := client.Get(w.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	callback, err := res.Location()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tamper(callback)
+
+	r := httptest.NewRequest(http.MethodGet, callback.RequestURI(), nil)
+	for _, c := range cookies {
+		r.AddCookie(c)
+	}
+	w = httptest.NewRecorder()
+	OIDCCallbackHandler(server).ServeHTTP(w, r)
+	return w
+}
+
+func TestOIDCLogin(t *testing.T) {
+	tests := []struct {
+		name   string
+		groups []string
+		tamper func(callback *url.URL)
+		want   int
+		scope  string
+	}{
+		{"admin group", []string{"staff", "ops"}, func(*url.URL) {}, http.StatusSeeOther, "admin"},
+		{"read group", []string{"staff"}, func(*url.URL) {}, http.StatusSeeOther, "read"},
+		{"no mapped group", []string{"sales"}, func(*url.URL) {}, http.StatusForbidden, ""},
+		{"state mismatch", []string{"ops"}, func(u *url.URL) {
+			q := u.Query()
+			q.Set("state", "forged")
+			u.RawQuery = q.Encode()
+		}, http.StatusUnauthorized, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			idp := newTestIdP(t, tt.groups)
+			store := &sessionStore{sessions: map[string]uuid.UUID{}}
+			config := NewServerConfig("", "", "", "templates")
+			config.OIDC = OIDCConfig{
+				Issuer:       idp.URL,
+				ClientID:     "tracker",
+				ClientSecret: "secret",
+				RedirectURL:  "http://tracker.test/login/oidc/callback",
+				GroupsClaim:  "groups",
+				GroupScopes:  map[string]Scope{"ops": ScopeAdmin, "staff": ScopeRead},
+			}
+			server := &Server{config: config, store: store, oidc: newOIDCClient(config.OIDC)}
+
+			w := oidcLogin(t, server, idp, tt.tamper)
+			if w.Code != tt.want {
+				t.Fatalf("want: %v, got %v: %s", tt.want, w.Code, w.Body.String())
+			}
+			if tt.want != http.StatusSeeOther {
+				if len(store.sessions) != 0 {
+					t.Error("session created for failed login")
+				}
+				return
+			}
+
+			if loc := w.Header().Get("Location"); loc != "/torrent/1" {
+				t.Errorf("want redirect to /torrent/1, got %v", loc)
+			}
+			if len(store.sessions) != 1 {
+				t.Fatalf("want 1 session, got %d", len(store.sessions))
+			}
+			if store.user.Username != "alice" || store.user.Scope != tt.scope || *store.user.Subject != idp.URL+" 1234" {
+				t.Errorf("unexpected user %+v", store.user)
+			}
+		})
+	}
+}
+
+func TestOIDCLoginUsernameTaken(t *testing.T) {
+	idp := newTestIdP(t, []string{"ops"})
+	// a local user already has the preferred_username of the subject
+	store := &sessionStore{sessions: map[string]uuid.UUID{}, user: User{ID: uuid.Must(uuid.NewV4()), Username: "alice", Scope: "admin"}}
+	config := NewServerConfig("", "", "", "templates")
+	config.OIDC = OIDCConfig{
+		Issuer:       idp.URL,
+		ClientID:     "tracker",
+		ClientSecret: "secret",
+		RedirectURL:  "http://tracker.test/login/oidc/callback",
+		GroupsClaim:  "groups",
+		GroupScopes:  map[string]Scope{"ops": ScopeAdmin},
+	}
+	server := &Server{config: config, store: store, oidc: newOIDCClient(config.OIDC)}
+
+	w := oidcLogin(t, server, idp, func(*url.URL) {})
+	if w.Code != http.StatusConflict {
+		t.Fatalf("want: %v, got %v: %s", http.StatusConflict, w.Code, w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), "belongs to another account") {
+		t.Errorf("want a login error, got %s", w.Body.String())
+	}
+	if len(store.sessions) != 0 || store.user.Subject != nil {
+		t.Errorf("want no session and the local user unchanged, got %+v", store.user)
+	}
+}
+
+func TestParseGroupScopes(t *testing.T) {
+	scopes, err := ParseGroupScopes("ops=admin, mods=moderate,staff=read")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if scopes["ops"] != ScopeAdmin || scopes["mods"] != ScopeModerate || scopes["staff"] != ScopeRead {
+		t.Errorf("unexpected scopes %v", scopes)
+	}
+
+	_, err = ParseGroupScopes("ops=root")
+	if err == nil {
+		t.Error("want error for unknown scope")
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"bytes"
 	"encoding/binary"
 	"net"
-	"strings"
 	"time"
 
 	"github.com/gofrs/uuid"
@@ -45,18 +44,14 @@ func (peer *Peer) Marshal() ([]byte, error) {
 
 // Tries to return client type based on peer_id
 func (peer *Peer) Client() string {
-	client := strings.ToLower(string(peer.PeerID))
-	if strings.HasPrefix(client, "-lt") {
-		return "libtorrent"
-	}
-	if strings.HasPrefix(client, "-tr") {
-		return "Transmission"
-	}
-	if strings.HasPrefix(client, "-ut") {
-		return "µtorrent"
-	}
-	if strings.HasPrefix(client, "-qb") {
-		return "qBittorrent"
-	}
-	return "unknown"
+	name, _ := ParseClient(peer.PeerID)
+	return name
+}
+
+// Pending peer write for a torrent.
+// UpdatedAt is the time the peer announced, not the time of the write.
+type PeerUpdate struct {
+	TorrentID uuid.UUID
+	Request   AnnounceRequest
+	UpdatedAt time.Time
 }
@@ -0,0 +1,198 @@
+package tracker
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Replays recorded requests against a running tracker or a handler.
+type Replayer struct {
+	// Base URL of the tracker, e.g. http://localhost:9999.
+	// Used when Handler is nil.
+	Target string
+	// Handler requests are served by directly.
+	Handler http.Handler
+	// Speed factor relative to the recording.
+	// 2 replays twice as fast, 0 replays as fast as possible.
+	Speed float64
+	// Maximum number of requests in flight.
+	Concurrency int
+	// Differences to the recording are written to Diff.
+	Diff io.Writer
+
+	client *http.Client
+}
+
+// Summary of a replay.
+type ReplayReport struct {
+	Requests   int
+	Mismatches int
+	Errors     int
+	Latencies  []time.Duration
+}
+
+// Returns latency at percentile p (0-100).
+func (rr *ReplayReport) Percentile(p float64) time.Duration {
+	if len(rr.Latencies) == 0 {
+		return 0
+	}
+	sorted := slices.Clone(rr.Latencies)
+	slices.Sort(sorted)
+	i := int(float64(len(sorted)-1) * p / 100)
+	return sorted[i]
+}
+
+func (rr *ReplayReport) String() string {
+	return fmt.Sprintf("requests: %d, mismatches: %d, errors: %d, latency p50: %s, p90: %s, p99: %s, max: %s",
+		rr.Requests, rr.Mismatches, rr.Errors,
+		rr.Percentile(50), rr.Percentile(90), rr.Percentile(99), rr.Percentile(100))
+}
+
+// Reads records from r as JSONL and replays them keeping their relative timing.
+func (rp *Replayer) Replay(ctx context.Context, r io.Reader) (*ReplayReport, error) {
+	if rp.client == nil {
+		rp.client = &http.Client{Timeout: 30 * time.Second}
+	}
+	concurrency := rp.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	report := &ReplayReport{}
+	mu := &sync.Mutex{}
+	wg := &sync.WaitGroup{}
+	sem := make(chan struct{}, concurrency)
+
+	var first time.Time
+	start := time.Now()
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		var record Record
+		err := json.Unmarshal(scanner.Bytes(), &record)
+		if err != nil {
+			return report, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		// wait until the record is due
+		if first.IsZero() {
+			first = record.Time
+		}
+		if rp.Speed > 0 {
+			due := start.Add(time.Duration(float64(record.Time.Sub(first)) / rp.Speed))
+			select {
+			case <-time.After(time.Until(due)):
+			case <-ctx.Done():
+				wg.Wait()
+				return report, ctx.Err()
+			}
+		}
+
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			wg.Wait()
+			return report, ctx.Err()
+		}
+
+		wg.Add(1)
+		go func(line int, record Record) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			status, reason, latency, err := rp.send(ctx, record)
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Requests++
+			if err != nil {
+				report.Errors++
+				rp.diff("line %d: %s %s: %v\n", line, record.Path, record.Query, err)
+				return
+			}
+			report.Latencies = append(report.Latencies, latency)
+			if status != record.Status || reason != record.FailureReason {
+				report.Mismatches++
+				rp.diff("line %d: %s %s: want: %d %q, got %d %q\n",
+					line, record.Path, record.Query, record.Status, record.FailureReason, status, reason)
+			}
+		}(line, record)
+	}
+	wg.Wait()
+
+	err := scanner.Err()
+	if err != nil {
+		return report, err
+	}
+
+	return report, nil
+}
+
+func (rp *Replayer) diff(format string, args ...any) {
+	if rp.Diff != nil {
+		fmt.Fprintf(rp.Diff, format, args...)
+	}
+}
+
+// Sends record as the recorded client and returns status and failure reason.
+func (rp *Replayer) send(ctx context.Context, record Record) (int, string, time.Duration, error) {
+	target := rp.Target
+	if rp.Handler != nil {
+		target = "http://tracker"
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target+record.Path+"?"+record.Query, nil)
+	if err != nil {
+		return 0, "", 0, err
+	}
+
+	if rp.Handler != nil {
+		req.RemoteAddr = net.JoinHostPort(record.IP, "0")
+
+		w := httptest.NewRecorder()
+		start := time.Now()
+		rp.Handler.ServeHTTP(w, req)
+		latency := time.Since(start)
+
+		reason := ""
+		if w.Code != http.StatusOK {
+			reason = failureReason(w.Body.Bytes())
+		}
+
+		return w.Code, reason, latency, nil
+	}
+
+	req.Header.Set("X-Forwarded-For", record.IP)
+
+	start := time.Now()
+	res, err := rp.client.Do(req)
+	if err != nil {
+		return 0, "", 0, err
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	latency := time.Since(start)
+	if err != nil {
+		return 0, "", 0, err
+	}
+
+	reason := ""
+	if res.StatusCode != http.StatusOK {
+		reason = failureReason(body)
+	}
+
+	return res.StatusCode, reason, latency, nil
+}
@@ -0,0 +1,76 @@
+package tracker
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestRecordReplay(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("info_hash") == "" {
+			replyBencode(w, ErrorResponse{FailureReason: "info_hash is not present"}, http.StatusBadRequest)
+			return
+		}
+		replyBencode(w, ScrapeResponse{}, http.StatusOK)
+	})
+
+	capture := &bytes.Buffer{}
+	recorded := RecorderMiddleware(capture)(handler)
+	for _, query := range []string{"info_hash=a", "", "info_hash=b"} {
+		r := httptest.NewRequest(http.MethodGet, "/scrape?"+query, nil)
+		recorded.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	replayer := &Replayer{Handler: handler, Concurrency: 2}
+	report, err := replayer.Replay(context.Background(), bytes.NewReader(capture.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Requests != 3 || report.Mismatches != 0 || report.Errors != 0 {
+		t.Errorf("want: 3 requests without mismatches, got %v", report)
+	}
+
+	// a changed handler shows up as a mismatch
+	replayer.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		replyBencode(w, ScrapeResponse{}, http.StatusOK)
+	})
+	report, err = replayer.Replay(context.Background(), bytes.NewReader(capture.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Mismatches != 1 {
+		t.Errorf("want: 1 mismatch, got %v", report)
+	}
+}
+
+func TestReplayPasskey(t *testing.T) {
+	config := NewServerConfig("", "http://localhost:9999/announce", "", "templates")
+	config.Private = true
+	server := &Server{config: config, store: &feedStore{user: User{Username: "alice", Passkey: "abc123"}}}
+	r := mux.NewRouter()
+	HandleTracker(r, server)
+
+	capture := &bytes.Buffer{}
+	recorded := RecorderMiddleware(capture)(r)
+	for _, target := range []string{"/announce/abc123?port=x", "/announce/wrong?port=6881", "/scrape/abc123"} {
+		w := httptest.NewRecorder()
+		recorded.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+		if w.Code == http.StatusNotFound && target != "/announce/wrong?port=6881" {
+			t.Fatalf("%s: want a tracker response, got 404", target)
+		}
+	}
+
+	replayer := &Replayer{Handler: r, Concurrency: 1}
+	report, err := replayer.Replay(context.Background(), bytes.NewReader(capture.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Requests != 3 || report.Mismatches != 0 || report.Errors != 0 {
+		t.Errorf("want: 3 passkey requests without mismatches, got %v", report)
+	}
+}
@@ -1,5 +1,7 @@
 package tracker
 
+import "time"
+
 type AnnounceRequest struct {
 	InfoHash []byte `db:"info_hash" validate:"required,ascii"`
 	PeerID   []byte `db:"peer_id" validate:"required,ascii,len=20"`
@@ -12,3 +14,16 @@ type AnnounceRequest struct {
 	Downloaded int `db:"downloaded" validate:"number"`
 	Left       int `db:"left" validate:"number"`
 }
+
+// Announce request as it is written to the announce log.
+type AnnounceLog struct {
+	AnnounceRequest
+	RequestID string    `db:"request_id"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+// Tries to return client type based on peer_id.
+func (l *AnnounceLog) Client() string {
+	peer := Peer{PeerID: l.PeerID}
+	return peer.Client()
+}
@@ -5,11 +5,15 @@ import (
 	"html/template"
 	"os"
 	"path"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/rs/zerolog/log"
+	"github.com/salimnassim/tracker/metric"
 )
 
 type Server struct {
@@ -18,6 +22,16 @@ type Server struct {
 	pool      *pgxpool.Pool
 	store     TorrentStorable
 	templates Templater
+
+	writeBehind *writeBehindStore
+	oidc        *oidcClient
+
+	// Announces handled since start, for the announce rate in stats.
+	announces  atomic.Int64
+	statsCache statsCache
+
+	// Last runs of background tasks, for readiness.
+	tasks taskHeartbeats
 }
 
 func NewServer(config *ServerConfig) *Server {
@@ -30,25 +44,74 @@ func NewServer(config *ServerConfig) *Server {
 		log.Fatal().Err(err).Msg("unable to create pgxpool")
 	}
 
-	return &Server{
+	server := &Server{
 		config:    config,
 		validator: validator.New(),
 		pool:      pgxpool,
-		store:     NewTorrentStore(pgxpool),
+		store:     NewInstrumentedStore(NewTorrentStore(pgxpool)),
 		templates: NewTemplateStore(),
 	}
+	prometheus.MustRegister(metric.NewPoolCollector(pgxpool))
+
+	if config.WriteBehind.FlushInterval > 0 {
+		server.writeBehind = NewWriteBehindStore(server.store, config.WriteBehind)
+		server.store = server.writeBehind
+	}
+
+	if config.OIDC.Issuer != "" {
+		server.oidc = newOIDCClient(config.OIDC)
+	}
+
+	return server
+}
+
+// Flushes pending writes and closes the store connection pool.
+func (sv *Server) Close(ctx context.Context) error {
+	if sv.writeBehind != nil {
+		err := sv.writeBehind.Close(ctx)
+		if err != nil {
+			return err
+		}
+	}
+	sv.pool.Close()
+	return nil
+}
+
+// Returns the server store.
+func (sv *Server) Store() TorrentStorable {
+	return sv.store
 }
 
 // Creates a goroutine that runs function f every duration d.
-// ts gives access to the store.
-func (sv *Server) RunTask(d time.Duration, f func(ts TorrentStorable)) {
+// ts gives access to the store. Runs without an error are recorded as heartbeats of task name,
+// so a task that keeps failing shows up as degraded in readiness checks.
+func (sv *Server) RunTask(name string, d time.Duration, f func(ts TorrentStorable) error) {
+	sv.tasks.add(name, d, time.Now())
 	go func() {
 		for range time.Tick(d) {
-			f(sv.store)
+			sv.runTask(name, f)
 		}
 	}()
 }
 
+// Runs f once and records a heartbeat of task name if it succeeds.
+func (sv *Server) runTask(name string, f func(ts TorrentStorable) error) {
+	err := f(sv.store)
+	if err != nil {
+		return
+	}
+	sv.tasks.beat(name, time.Now())
+}
+
+// Registers the announce and scrape routes on r, with and without a passkey
+// in the path. The tracker and replays against an in-process server share them.
+func HandleTracker(r *mux.Router, server *Server) {
+	r.Handle("/announce", AnnounceHandler(server))
+	r.Handle("/scrape", ScrapeHandler(server))
+	r.Handle("/announce/{passkey}", AnnounceHandler(server))
+	r.Handle("/scrape/{passkey}", ScrapeHandler(server))
+}
+
 func (sv *Server) CacheTemplates() {
 	// index
 	tplIndex := template.Must(
@@ -0,0 +1,160 @@
+package tracker
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	sessionCookie = "tracker_session"
+	csrfCookie    = "tracker_csrf"
+	csrfField     = "csrf_token"
+	csrfHeader    = "X-CSRF-Token"
+
+	// Maximum size of form bodies read to find the CSRF token.
+	maxFormSize = 16 << 20
+)
+
+// Generates a random url safe string.
+func randomString() (string, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Creates a session for user and sets its cookie.
+// Only the hash of the cookie is stored.
+func (sv *Server) startSession(w http.ResponseWriter, r *http.Request, user User) error {
+	secret, err := randomString()
+	if err != nil {
+		return err
+	}
+
+	expiresAt := time.Now().Add(sv.config.SessionTTL)
+	err = sv.store.AddSession(r.Context(), user.ID, HashTokenSecret(secret), expiresAt)
+	if err != nil {
+		return err
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    secret,
+		Path:     "/",
+		Expires:  expiresAt,
+		HttpOnly: true,
+		Secure:   sv.config.SessionCookieSecure,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return nil
+}
+
+// Removes the session of r and expires its cookie.
+func (sv *Server) endSession(w http.ResponseWriter, r *http.Request) error {
+	cookie, err := r.Cookie(sessionCookie)
+	if err == nil {
+		err = sv.store.DeleteSession(r.Context(), HashTokenSecret(cookie.Value))
+		if err != nil {
+			return err
+		}
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   sv.config.SessionCookieSecure,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return nil
+}
+
+// Returns the user of the session cookie of r.
+// ok is false if there is no cookie or the session has expired.
+func (sv *Server) sessionUser(r *http.Request) (user User, ok bool, err error) {
+	cookie, err := r.Cookie(sessionCookie)
+	if err != nil {
+		return User{}, false, nil
+	}
+
+	user, err = sv.store.SessionUser(r.Context(), HashTokenSecret(cookie.Value))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return User{}, false, nil
+	}
+	if err != nil {
+		return User{}, false, err
+	}
+
+	return user, true, nil
+}
+
+// Returns the CSRF token of r, setting a new cookie if there is none.
+// Forms echo the token back in a hidden field (double submit cookie).
+func (sv *Server) csrfToken(w http.ResponseWriter, r *http.Request) (string, error) {
+	cookie, err := r.Cookie(csrfCookie)
+	if err == nil && cookie.Value != "" {
+		return cookie.Value, nil
+	}
+
+	token, err := randomString()
+	if err != nil {
+		return "", err
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     csrfCookie,
+		Value:    token,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   sv.config.SessionCookieSecure,
+		SameSite: http.SameSiteStrictMode,
+	})
+	return token, nil
+}
+
+// Error of state changing requests from a browser without a valid CSRF token.
+var errInvalidCSRF = errors.New("csrf token is not valid")
+
+// Reports whether r may have been sent by a browser. Browsers resend cookies
+// and cached basic auth credentials on cross-site requests, API clients
+// usually send neither cookies nor an Origin.
+func browserRequest(r *http.Request) bool {
+	return len(r.Cookies()) > 0 || r.Header.Get("Origin") != "" || r.Header.Get("Sec-Fetch-Site") != ""
+}
+
+// Reports whether the CSRF header or form field of r matches its cookie.
+func validCSRF(r *http.Request) bool {
+	cookie, err := r.Cookie(csrfCookie)
+	if err != nil || cookie.Value == "" {
+		return false
+	}
+
+	token := r.Header.Get(csrfHeader)
+	if token == "" {
+		r.Body = http.MaxBytesReader(nil, r.Body, maxFormSize)
+		if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+			r.ParseMultipartForm(maxFormSize)
+		}
+		token = r.PostFormValue(csrfField)
+	}
+	return subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(token)) == 1
+}
+
+// Returns next if it is a local path, otherwise /.
+func safeRedirect(next string) string {
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		return "/"
+	}
+	return next
+}
@@ -0,0 +1,90 @@
+package tracker
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/salimnassim/tracker/metric"
+)
+
+// Tracker totals. The counters are summed by the store, the announce rate
+// is measured by the server and everything is refreshed periodically by RefreshStats.
+type Stats struct {
+	Torrents  int `db:"torrents" json:"torrents"`
+	Peers     int `db:"peers" json:"peers"`
+	Seeders   int `db:"seeders" json:"seeders"`
+	Leechers  int `db:"leechers" json:"leechers"`
+	Completed int `db:"completed" json:"completed"`
+
+	IPv4Peers int `db:"ipv4_peers" json:"ipv4_peers"`
+	IPv6Peers int `db:"ipv6_peers" json:"ipv6_peers"`
+	// Distinct IPs in the announce log of the last 24 hours and of current peers,
+	// of torrents that are not hidden like the other peer counts.
+	// With a log sample rate below 1 peers that left without an event may be missed.
+	UniqueIPs int `db:"unique_ips" json:"unique_ips_24h"`
+
+	// Peers by client name, most peers first.
+	Clients []ClientCount `db:"-" json:"clients"`
+	// Announces handled by this server per minute between the last two refreshes.
+	AnnouncesPerMinute float64   `db:"-" json:"announces_per_minute"`
+	UpdatedAt          time.Time `db:"-" json:"updated_at"`
+}
+
+// Returns the share of IPv6 peers in percent.
+func (s Stats) IPv6Share() float64 {
+	if s.IPv4Peers+s.IPv6Peers == 0 {
+		return 0
+	}
+	return 100 * float64(s.IPv6Peers) / float64(s.IPv4Peers+s.IPv6Peers)
+}
+
+// Last computed stats and the announce count they were computed at.
+type statsCache struct {
+	mu        sync.Mutex
+	stats     *Stats
+	announces int64
+}
+
+// Computes the stats and replaces the cached ones.
+func (sv *Server) RefreshStats(ctx context.Context) (Stats, error) {
+	stats, err := sv.store.Stats(ctx)
+	if err != nil {
+		return Stats{}, err
+	}
+	stats.UpdatedAt = time.Now()
+	if stats.Clients == nil {
+		stats.Clients = []ClientCount{}
+	}
+	announces := sv.announces.Load()
+
+	sv.statsCache.mu.Lock()
+	defer sv.statsCache.mu.Unlock()
+
+	if prev := sv.statsCache.stats; prev != nil {
+		if minutes := stats.UpdatedAt.Sub(prev.UpdatedAt).Minutes(); minutes > 0 {
+			stats.AnnouncesPerMinute = float64(announces-sv.statsCache.announces) / minutes
+		}
+	}
+	sv.statsCache.stats = &stats
+	sv.statsCache.announces = announces
+
+	metric.TrackerSwarmTorrents.Set(float64(stats.Torrents))
+	metric.TrackerSwarmPeers.Set(float64(stats.Peers))
+	metric.TrackerSwarmSeeders.Set(float64(stats.Seeders))
+	metric.TrackerSwarmLeechers.Set(float64(stats.Leechers))
+
+	return stats, nil
+}
+
+// Returns the cached stats, computing them if there are none yet.
+func (sv *Server) Stats(ctx context.Context) (Stats, error) {
+	sv.statsCache.mu.Lock()
+	stats := sv.statsCache.stats
+	sv.statsCache.mu.Unlock()
+
+	if stats != nil {
+		return *stats, nil
+	}
+	return sv.RefreshStats(ctx)
+}
@@ -0,0 +1,187 @@
+package tracker
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/gofrs/uuid"
+	pgx "github.com/jackc/pgx/v5"
+)
+
+func (ts *torrentStore) DeleteTorrent(ctx context.Context, torrentID uuid.UUID) error {
+	query := `delete from torrents where id = $1`
+
+	tag, err := ts.pool.Exec(ctx, query, torrentID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	return nil
+}
+
+func (ts *torrentStore) UpdateTorrentFlags(ctx context.Context, torrentID uuid.UUID, set []string, unset []string) (Torrent, error) {
+	query := `update torrents t set flags = array(
+		select distinct flag from unnest(array_cat(t.flags, $2::text[])) as flag
+		where flag != all($3::text[])
+		order by flag
+	)
+	where t.id = $1
+	returning ` + torrentColumns
+
+	if set == nil {
+		set = []string{}
+	}
+	if unset == nil {
+		unset = []string{}
+	}
+
+	rows, err := ts.pool.Query(ctx, query, torrentID, set, unset)
+	if err != nil {
+		return Torrent{}, err
+	}
+	defer rows.Close()
+
+	torrent, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[Torrent])
+	if err != nil {
+		return Torrent{}, err
+	}
+
+	return torrent, nil
+}
+
+func (ts *torrentStore) UpdateTorrentLabels(ctx context.Context, torrentID uuid.UUID, name string, category string, tags []string) (Torrent, error) {
+	query := `update torrents t set name = $2, category = $3, tags = $4
+	where t.id = $1
+	returning ` + torrentColumns
+
+	if tags == nil {
+		tags = []string{}
+	}
+
+	rows, err := ts.pool.Query(ctx, query, torrentID, name, category, tags)
+	if err != nil {
+		return Torrent{}, err
+	}
+	defer rows.Close()
+
+	torrent, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[Torrent])
+	if err != nil {
+		return Torrent{}, err
+	}
+
+	return torrent, nil
+}
+
+func (ts *torrentStore) ResetCompleted(ctx context.Context, torrentID uuid.UUID) error {
+	query := `update torrents set completed = 0 where id = $1`
+
+	tag, err := ts.pool.Exec(ctx, query, torrentID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	return nil
+}
+
+func (ts *torrentStore) EvictPeer(ctx context.Context, torrentID uuid.UUID, peerID uuid.UUID) (Peer, error) {
+	query := `delete from peers where torrent_id = $1 and id = $2
+	returning id, torrent_id, peer_id, ip, port, uploaded, downloaded, "left", event, key, updated_at`
+
+	rows, err := ts.pool.Query(ctx, query, torrentID, peerID)
+	if err != nil {
+		return Peer{}, err
+	}
+
+	peer, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[Peer])
+	if err != nil {
+		return Peer{}, err
+	}
+
+	return peer, nil
+}
+
+func (ts *torrentStore) EvictPeersByIP(ctx context.Context, ip string) ([]Peer, error) {
+	query := `delete from peers where ip = $1::inet
+	returning id, torrent_id, peer_id, ip, port, uploaded, downloaded, "left", event, key, updated_at`
+
+	rows, err := ts.pool.Query(ctx, query, ip)
+	if err != nil {
+		return nil, err
+	}
+
+	peers, err := pgx.CollectRows(rows, pgx.RowToStructByName[Peer])
+	if err != nil {
+		return nil, err
+	}
+
+	return peers, nil
+}
+
+func (ts *torrentStore) AddAuditLog(ctx context.Context, entry AuditLog) error {
+	query := `insert into audit_log (id, actor, action, target, old_value, new_value, details, created_at)
+	values (gen_random_uuid(), $1, $2, $3, $4, $5, $6, now())`
+
+	details := entry.Details
+	if details == nil {
+		details = map[string]any{}
+	}
+
+	_, err := ts.pool.Exec(ctx, query, entry.Actor, entry.Action, entry.Target, entry.OldValue, entry.NewValue, details)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (ts *torrentStore) AuditLogs(ctx context.Context, filter AuditLogFilter) ([]AuditLog, error) {
+	var where []string
+	var args []any
+	add := func(cond string, arg any) {
+		args = append(args, arg)
+		where = append(where, fmt.Sprintf(cond, len(args)))
+	}
+
+	if filter.Actor != "" {
+		add("actor = $%d", filter.Actor)
+	}
+	if filter.Action != "" {
+		add("action = $%d", filter.Action)
+	}
+	if filter.Target != "" {
+		add("target = $%d", filter.Target)
+	}
+	if !filter.From.IsZero() {
+		add("created_at >= $%d", filter.From)
+	}
+	if !filter.To.IsZero() {
+		add("created_at < $%d", filter.To)
+	}
+
+	query := `select id, actor, action, target, old_value, new_value, details, created_at
+	from audit_log`
+	if len(where) > 0 {
+		query += "\n\twhere " + strings.Join(where, " and ")
+	}
+	args = append(args, filter.Limit)
+	query += fmt.Sprintf("\n\torder by created_at desc\n\tlimit $%d", len(args))
+
+	rows, err := ts.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	logs, err := pgx.CollectRows(rows, pgx.RowToStructByName[AuditLog])
+	if err != nil {
+		return nil, err
+	}
+
+	return logs, nil
+}
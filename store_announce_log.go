@@ -0,0 +1,186 @@
+package tracker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	pgx "github.com/jackc/pgx/v5"
+)
+
+// Announce log partitions cover one UTC day each.
+const logPartitionPrefix = "announce_log_p"
+const logPartitionLayout = "20060102"
+
+func (ts *torrentStore) CreateLogPartitions(ctx context.Context, from time.Time, days int) (int, error) {
+	day := from.UTC().Truncate(24 * time.Hour)
+
+	created := 0
+	var errs []error
+	for i := 0; i < days; i++ {
+		start := day.AddDate(0, 0, i)
+		err := ts.createLogPartition(ctx, start, start.AddDate(0, 0, 1))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("partition for %s: %w", start.Format(time.DateOnly), err))
+			continue
+		}
+		created++
+	}
+
+	return created, errors.Join(errs...)
+}
+
+// Creates the partition for rows between start and end.
+// Postgres refuses to create a partition for rows that are in the default partition,
+// e.g. after downtime, so those are moved with the default partition detached.
+func (ts *torrentStore) createLogPartition(ctx context.Context, start time.Time, end time.Time) error {
+	name := pgx.Identifier{logPartitionPrefix + start.Format(logPartitionLayout)}.Sanitize()
+
+	// ddl does not take parameters, the bounds are formatted by us
+	create := fmt.Sprintf(`create table if not exists %s partition of announce_log
+	for values from ('%s') to ('%s')`,
+		name, start.Format(time.RFC3339), end.Format(time.RFC3339))
+
+	var conflicting bool
+	err := ts.pool.QueryRow(ctx, `select to_regclass($1) is null and exists (
+		select 1 from announce_log_default where created_at >= $2 and created_at < $3
+	)`, name, start, end).Scan(&conflicting)
+	if err != nil {
+		return err
+	}
+	if !conflicting {
+		_, err = ts.pool.Exec(ctx, create)
+		return err
+	}
+
+	tx, err := ts.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `alter table announce_log detach partition announce_log_default`)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, create)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, `with moved as (
+		delete from announce_log_default where created_at >= $1 and created_at < $2 returning *
+	)
+	insert into announce_log select * from moved`, start, end)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, `alter table announce_log attach partition announce_log_default default`)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (ts *torrentStore) DropLogPartitions(ctx context.Context, before time.Time) (int, error) {
+	query := `select c.relname
+	from pg_inherits i
+	join pg_class c on c.oid = i.inhrelid
+	join pg_class p on p.oid = i.inhparent
+	where p.relname = 'announce_log'`
+
+	rows, err := ts.pool.Query(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+	names, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return 0, err
+	}
+
+	dropped := 0
+	for _, name := range names {
+		if !strings.HasPrefix(name, logPartitionPrefix) {
+			continue
+		}
+		day, err := time.Parse(logPartitionLayout, strings.TrimPrefix(name, logPartitionPrefix))
+		if err != nil {
+			continue
+		}
+		// drop only partitions that end before the cutoff
+		if day.AddDate(0, 0, 1).After(before) {
+			continue
+		}
+
+		_, err = ts.pool.Exec(ctx, "drop table if exists "+pgx.Identifier{name}.Sanitize())
+		if err != nil {
+			return dropped, err
+		}
+		dropped++
+	}
+
+	// rows that did not have a partition
+	_, err = ts.pool.Exec(ctx, `delete from announce_log_default where created_at < $1`, before)
+	if err != nil {
+		return dropped, err
+	}
+
+	return dropped, nil
+}
+
+// Filter for searching the announce log. Zero fields are not filtered on.
+type AnnounceLogFilter struct {
+	InfoHash []byte
+	PeerID   []byte
+	IP       string
+	From     time.Time
+	To       time.Time
+	Limit    int
+}
+
+func (ts *torrentStore) AnnounceLogs(ctx context.Context, filter AnnounceLogFilter) ([]AnnounceLog, error) {
+	var where []string
+	var args []any
+	add := func(cond string, arg any) {
+		args = append(args, arg)
+		where = append(where, fmt.Sprintf(cond, len(args)))
+	}
+
+	if len(filter.InfoHash) > 0 {
+		add("info_hash = $%d", filter.InfoHash)
+	}
+	if len(filter.PeerID) > 0 {
+		add("peer_id = $%d", filter.PeerID)
+	}
+	if filter.IP != "" {
+		add("ip = $%d::inet", filter.IP)
+	}
+	if !filter.From.IsZero() {
+		add("created_at >= $%d", filter.From)
+	}
+	if !filter.To.IsZero() {
+		add("created_at < $%d", filter.To)
+	}
+
+	query := `select info_hash, peer_id, event, host(ip) as ip, port, key, uploaded, downloaded, "left", request_id, created_at
+	from announce_log`
+	if len(where) > 0 {
+		query += "\n\twhere " + strings.Join(where, " and ")
+	}
+	args = append(args, filter.Limit)
+	query += fmt.Sprintf("\n\torder by created_at desc\n\tlimit $%d", len(args))
+
+	rows, err := ts.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	logs, err := pgx.CollectRows(rows, pgx.RowToStructByName[AnnounceLog])
+	if err != nil {
+		return nil, err
+	}
+
+	return logs, nil
+}
@@ -0,0 +1,94 @@
+package tracker
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestLogPartitions(t *testing.T) {
+	server := newTestServer(t)
+	ctx := context.Background()
+	store := server.store
+
+	// days far in the past so real partitions are not touched
+	day := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	drop := func() {
+		for _, name := range []string{"announce_log_p20000101", "announce_log_p20000102"} {
+			server.pool.Exec(ctx, "drop table if exists "+name)
+		}
+		server.pool.Exec(ctx, `delete from announce_log_default where created_at < $1`, day.AddDate(0, 0, 2))
+	}
+	drop()
+	t.Cleanup(drop)
+
+	count := func(table string) int {
+		t.Helper()
+		var n int
+		err := server.pool.QueryRow(ctx, fmt.Sprintf(`select count(*) from %s where created_at < $1`, table), day.AddDate(0, 0, 2)).Scan(&n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+	exists := func(name string) bool {
+		t.Helper()
+		var ok bool
+		err := server.pool.QueryRow(ctx, `select to_regclass($1) is not null`, name).Scan(&ok)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ok
+	}
+
+	// rows logged before their partition exists land in the default partition
+	var logs []AnnounceLog
+	for i := 0; i < 3; i++ {
+		logs = append(logs, AnnounceLog{
+			AnnounceRequest: AnnounceRequest{
+				InfoHash: []byte("aaaaaaaaaaaaaaaaaaaa"),
+				PeerID:   []byte(fmt.Sprintf("-TR3000-%012d", i)),
+				IP:       "127.0.0.1",
+				Port:     6881,
+			},
+			CreatedAt: day.Add(time.Duration(i) * time.Hour),
+		})
+	}
+	logs = append(logs, AnnounceLog{AnnounceRequest: logs[0].AnnounceRequest, CreatedAt: day.AddDate(0, 0, -1)})
+	err := store.LogMany(ctx, logs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := store.CreateLogPartitions(ctx, day, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 || !exists("announce_log_p20000101") || !exists("announce_log_p20000102") {
+		t.Fatalf("want 2 partitions, got %d", n)
+	}
+	if got := count("announce_log_p20000101"); got != 3 {
+		t.Errorf("want rows moved to the partition, got %d", got)
+	}
+	if got := count("announce_log_default"); got != 1 {
+		t.Errorf("want only the row of the day before in the default partition, got %d", got)
+	}
+
+	// creating again is a no-op
+	n, err = store.CreateLogPartitions(ctx, day, 2)
+	if err != nil || n != 2 {
+		t.Fatalf("want existing partitions, got %d, %v", n, err)
+	}
+
+	n, err = store.DropLogPartitions(ctx, day.AddDate(0, 0, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 || exists("announce_log_p20000101") || !exists("announce_log_p20000102") {
+		t.Errorf("want only the first day dropped, got %d", n)
+	}
+	if got := count("announce_log_default"); got != 0 {
+		t.Errorf("want expired rows deleted from the default partition, got %d", got)
+	}
+}
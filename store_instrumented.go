@@ -0,0 +1,378 @@
+package tracker
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog"
+	"github.com/salimnassim/tracker/metainfo"
+	"github.com/salimnassim/tracker/metric"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Store that measures, traces and debug logs every call to the wrapped store.
+type instrumentedStore struct {
+	store TorrentStorable
+}
+
+func NewInstrumentedStore(store TorrentStorable) TorrentStorable {
+	return &instrumentedStore{store: store}
+}
+
+// Starts a call of method in a span with attrs.
+// The returned function records the duration and result of the call and ends the span.
+func (s *instrumentedStore) start(ctx context.Context, method string, attrs ...attribute.KeyValue) (context.Context, func(err error)) {
+	start := time.Now()
+	ctx, span := tracer().Start(ctx, "store."+method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...))
+
+	return ctx, func(err error) {
+		// a missing row is a result, not a failure
+		result := "ok"
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			result = "error"
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		elapsed := time.Since(start)
+		metric.TrackerStoreSeconds.WithLabelValues(method, result).Observe(elapsed.Seconds())
+		zerolog.Ctx(ctx).Debug().Err(err).Str("source", "store").Str("method", method).Str("result", result).Dur("latency", elapsed).Msg("store call")
+		span.End()
+	}
+}
+
+func (s *instrumentedStore) AddTorrent(ctx context.Context, infoHash []byte) (_ Torrent, err error) {
+	ctx, done := s.start(ctx, "AddTorrent", infoHashAttr(infoHash))
+	defer func() { done(err) }()
+	return s.store.AddTorrent(ctx, infoHash)
+}
+
+func (s *instrumentedStore) Torrent(ctx context.Context, infoHash []byte) (_ Torrent, err error) {
+	ctx, done := s.start(ctx, "Torrent", infoHashAttr(infoHash))
+	defer func() { done(err) }()
+	return s.store.Torrent(ctx, infoHash)
+}
+
+func (s *instrumentedStore) GetOrAddTorrent(ctx context.Context, infoHash []byte) (_ Torrent, _ bool, err error) {
+	ctx, done := s.start(ctx, "GetOrAddTorrent", infoHashAttr(infoHash))
+	defer func() { done(err) }()
+	return s.store.GetOrAddTorrent(ctx, infoHash)
+}
+
+func (s *instrumentedStore) IncrementTorrent(ctx context.Context, torrentID uuid.UUID) (err error) {
+	ctx, done := s.start(ctx, "IncrementTorrent", torrentIDAttr(torrentID))
+	defer func() { done(err) }()
+	return s.store.IncrementTorrent(ctx, torrentID)
+}
+
+func (s *instrumentedStore) TorrentByID(ctx context.Context, torrentID uuid.UUID) (_ Torrent, err error) {
+	ctx, done := s.start(ctx, "TorrentByID", torrentIDAttr(torrentID))
+	defer func() { done(err) }()
+	return s.store.TorrentByID(ctx, torrentID)
+}
+
+func (s *instrumentedStore) ListTorrents(ctx context.Context, query TorrentQuery) (_ []Torrent, _ int, err error) {
+	ctx, done := s.start(ctx, "ListTorrents")
+	defer func() { done(err) }()
+	return s.store.ListTorrents(ctx, query)
+}
+
+func (s *instrumentedStore) Categories(ctx context.Context) (_ []Category, err error) {
+	ctx, done := s.start(ctx, "Categories")
+	defer func() { done(err) }()
+	return s.store.Categories(ctx)
+}
+
+func (s *instrumentedStore) Scrape(ctx context.Context, hashes [][]byte) (_ []Torrent, err error) {
+	ctx, done := s.start(ctx, "Scrape")
+	defer func() { done(err) }()
+	return s.store.Scrape(ctx, hashes)
+}
+
+func (s *instrumentedStore) Peers(ctx context.Context, torrentID uuid.UUID) (_ []Peer, err error) {
+	ctx, done := s.start(ctx, "Peers", torrentIDAttr(torrentID))
+	defer func() { done(err) }()
+	return s.store.Peers(ctx, torrentID)
+}
+
+func (s *instrumentedStore) ListPeers(ctx context.Context, torrentID uuid.UUID, limit int, offset int) (_ []Peer, err error) {
+	ctx, done := s.start(ctx, "ListPeers", torrentIDAttr(torrentID))
+	defer func() { done(err) }()
+	return s.store.ListPeers(ctx, torrentID, limit, offset)
+}
+
+func (s *instrumentedStore) UpdatePeerWithKey(ctx context.Context, torrentID uuid.UUID, req AnnounceRequest) (_ bool, err error) {
+	ctx, done := s.start(ctx, "UpdatePeerWithKey", torrentIDAttr(torrentID), infoHashAttr(req.InfoHash), eventAttr(req.Event))
+	defer func() { done(err) }()
+	return s.store.UpdatePeerWithKey(ctx, torrentID, req)
+}
+
+func (s *instrumentedStore) UpsertPeer(ctx context.Context, torrentID uuid.UUID, req AnnounceRequest) (err error) {
+	ctx, done := s.start(ctx, "UpsertPeer", torrentIDAttr(torrentID), infoHashAttr(req.InfoHash), eventAttr(req.Event))
+	defer func() { done(err) }()
+	return s.store.UpsertPeer(ctx, torrentID, req)
+}
+
+func (s *instrumentedStore) UpsertPeers(ctx context.Context, updates []PeerUpdate) (err error) {
+	ctx, done := s.start(ctx, "UpsertPeers")
+	defer func() { done(err) }()
+	return s.store.UpsertPeers(ctx, updates)
+}
+
+func (s *instrumentedStore) CleanPeers(ctx context.Context, interval time.Duration) (_ int, err error) {
+	ctx, done := s.start(ctx, "CleanPeers")
+	defer func() { done(err) }()
+	return s.store.CleanPeers(ctx, interval)
+}
+
+func (s *instrumentedStore) ReconcileTorrents(ctx context.Context) (_ int, err error) {
+	ctx, done := s.start(ctx, "ReconcileTorrents")
+	defer func() { done(err) }()
+	return s.store.ReconcileTorrents(ctx)
+}
+
+func (s *instrumentedStore) Log(ctx context.Context, req AnnounceRequest) (err error) {
+	ctx, done := s.start(ctx, "Log", infoHashAttr(req.InfoHash), eventAttr(req.Event))
+	defer func() { done(err) }()
+	return s.store.Log(ctx, req)
+}
+
+func (s *instrumentedStore) LogMany(ctx context.Context, logs []AnnounceLog) (err error) {
+	ctx, done := s.start(ctx, "LogMany")
+	defer func() { done(err) }()
+	return s.store.LogMany(ctx, logs)
+}
+
+func (s *instrumentedStore) CreateLogPartitions(ctx context.Context, from time.Time, days int) (_ int, err error) {
+	ctx, done := s.start(ctx, "CreateLogPartitions")
+	defer func() { done(err) }()
+	return s.store.CreateLogPartitions(ctx, from, days)
+}
+
+func (s *instrumentedStore) DropLogPartitions(ctx context.Context, before time.Time) (_ int, err error) {
+	ctx, done := s.start(ctx, "DropLogPartitions")
+	defer func() { done(err) }()
+	return s.store.DropLogPartitions(ctx, before)
+}
+
+func (s *instrumentedStore) AnnounceLogs(ctx context.Context, filter AnnounceLogFilter) (_ []AnnounceLog, err error) {
+	ctx, done := s.start(ctx, "AnnounceLogs")
+	defer func() { done(err) }()
+	return s.store.AnnounceLogs(ctx, filter)
+}
+
+func (s *instrumentedStore) SwarmActivity(ctx context.Context, infoHash []byte, from time.Time, step string) (_ []SwarmActivity, err error) {
+	ctx, done := s.start(ctx, "SwarmActivity", infoHashAttr(infoHash))
+	defer func() { done(err) }()
+	return s.store.SwarmActivity(ctx, infoHash, from, step)
+}
+
+func (s *instrumentedStore) PeerClients(ctx context.Context, torrentID uuid.UUID) (_ []ClientCount, err error) {
+	ctx, done := s.start(ctx, "PeerClients", torrentIDAttr(torrentID))
+	defer func() { done(err) }()
+	return s.store.PeerClients(ctx, torrentID)
+}
+
+func (s *instrumentedStore) SnapshotSwarms(ctx context.Context, at time.Time) (_ int, err error) {
+	ctx, done := s.start(ctx, "SnapshotSwarms")
+	defer func() { done(err) }()
+	return s.store.SnapshotSwarms(ctx, at)
+}
+
+func (s *instrumentedStore) RollupSnapshots(ctx context.Context, resolution string, before time.Time) (_ int, err error) {
+	ctx, done := s.start(ctx, "RollupSnapshots")
+	defer func() { done(err) }()
+	return s.store.RollupSnapshots(ctx, resolution, before)
+}
+
+func (s *instrumentedStore) DropSnapshots(ctx context.Context, resolution string, before time.Time) (_ int, err error) {
+	ctx, done := s.start(ctx, "DropSnapshots")
+	defer func() { done(err) }()
+	return s.store.DropSnapshots(ctx, resolution, before)
+}
+
+func (s *instrumentedStore) SwarmHistory(ctx context.Context, torrentID uuid.UUID, resolution string, from time.Time) (_ []SwarmSnapshot, err error) {
+	ctx, done := s.start(ctx, "SwarmHistory", torrentIDAttr(torrentID))
+	defer func() { done(err) }()
+	return s.store.SwarmHistory(ctx, torrentID, resolution, from)
+}
+
+func (s *instrumentedStore) DeleteTorrent(ctx context.Context, torrentID uuid.UUID) (err error) {
+	ctx, done := s.start(ctx, "DeleteTorrent", torrentIDAttr(torrentID))
+	defer func() { done(err) }()
+	return s.store.DeleteTorrent(ctx, torrentID)
+}
+
+func (s *instrumentedStore) UpdateTorrentFlags(ctx context.Context, torrentID uuid.UUID, set []string, unset []string) (_ Torrent, err error) {
+	ctx, done := s.start(ctx, "UpdateTorrentFlags", torrentIDAttr(torrentID))
+	defer func() { done(err) }()
+	return s.store.UpdateTorrentFlags(ctx, torrentID, set, unset)
+}
+
+func (s *instrumentedStore) UpdateTorrentLabels(ctx context.Context, torrentID uuid.UUID, name string, category string, tags []string) (_ Torrent, err error) {
+	ctx, done := s.start(ctx, "UpdateTorrentLabels", torrentIDAttr(torrentID))
+	defer func() { done(err) }()
+	return s.store.UpdateTorrentLabels(ctx, torrentID, name, category, tags)
+}
+
+func (s *instrumentedStore) ResetCompleted(ctx context.Context, torrentID uuid.UUID) (err error) {
+	ctx, done := s.start(ctx, "ResetCompleted", torrentIDAttr(torrentID))
+	defer func() { done(err) }()
+	return s.store.ResetCompleted(ctx, torrentID)
+}
+
+func (s *instrumentedStore) EvictPeer(ctx context.Context, torrentID uuid.UUID, peerID uuid.UUID) (_ Peer, err error) {
+	ctx, done := s.start(ctx, "EvictPeer", torrentIDAttr(torrentID))
+	defer func() { done(err) }()
+	return s.store.EvictPeer(ctx, torrentID, peerID)
+}
+
+func (s *instrumentedStore) EvictPeersByIP(ctx context.Context, ip string) (_ []Peer, err error) {
+	ctx, done := s.start(ctx, "EvictPeersByIP")
+	defer func() { done(err) }()
+	return s.store.EvictPeersByIP(ctx, ip)
+}
+
+func (s *instrumentedStore) AddAuditLog(ctx context.Context, entry AuditLog) (err error) {
+	ctx, done := s.start(ctx, "AddAuditLog")
+	defer func() { done(err) }()
+	return s.store.AddAuditLog(ctx, entry)
+}
+
+func (s *instrumentedStore) AuditLogs(ctx context.Context, filter AuditLogFilter) (_ []AuditLog, err error) {
+	ctx, done := s.start(ctx, "AuditLogs")
+	defer func() { done(err) }()
+	return s.store.AuditLogs(ctx, filter)
+}
+
+func (s *instrumentedStore) AddToken(ctx context.Context, name string, scope Scope, hash []byte) (_ APIToken, err error) {
+	ctx, done := s.start(ctx, "AddToken")
+	defer func() { done(err) }()
+	return s.store.AddToken(ctx, name, scope, hash)
+}
+
+func (s *instrumentedStore) TokenByHash(ctx context.Context, hash []byte) (_ APIToken, err error) {
+	ctx, done := s.start(ctx, "TokenByHash")
+	defer func() { done(err) }()
+	return s.store.TokenByHash(ctx, hash)
+}
+
+func (s *instrumentedStore) TouchToken(ctx context.Context, tokenID uuid.UUID) (err error) {
+	ctx, done := s.start(ctx, "TouchToken")
+	defer func() { done(err) }()
+	return s.store.TouchToken(ctx, tokenID)
+}
+
+func (s *instrumentedStore) RevokeToken(ctx context.Context, tokenID uuid.UUID) (err error) {
+	ctx, done := s.start(ctx, "RevokeToken")
+	defer func() { done(err) }()
+	return s.store.RevokeToken(ctx, tokenID)
+}
+
+func (s *instrumentedStore) Tokens(ctx context.Context) (_ []APIToken, err error) {
+	ctx, done := s.start(ctx, "Tokens")
+	defer func() { done(err) }()
+	return s.store.Tokens(ctx)
+}
+
+func (s *instrumentedStore) AddUser(ctx context.Context, username string, passwordHash string, scope Scope) (_ User, err error) {
+	ctx, done := s.start(ctx, "AddUser")
+	defer func() { done(err) }()
+	return s.store.AddUser(ctx, username, passwordHash, scope)
+}
+
+func (s *instrumentedStore) UpsertSubjectUser(ctx context.Context, subject string, username string, scope Scope) (_ User, err error) {
+	ctx, done := s.start(ctx, "UpsertSubjectUser")
+	defer func() { done(err) }()
+	return s.store.UpsertSubjectUser(ctx, subject, username, scope)
+}
+
+func (s *instrumentedStore) UserByUsername(ctx context.Context, username string) (_ User, err error) {
+	ctx, done := s.start(ctx, "UserByUsername")
+	defer func() { done(err) }()
+	return s.store.UserByUsername(ctx, username)
+}
+
+func (s *instrumentedStore) UserByPasskey(ctx context.Context, passkey string) (_ User, err error) {
+	ctx, done := s.start(ctx, "UserByPasskey")
+	defer func() { done(err) }()
+	return s.store.UserByPasskey(ctx, passkey)
+}
+
+func (s *instrumentedStore) ResetPasskey(ctx context.Context, username string) (_ string, err error) {
+	ctx, done := s.start(ctx, "ResetPasskey")
+	defer func() { done(err) }()
+	return s.store.ResetPasskey(ctx, username)
+}
+
+func (s *instrumentedStore) UpdateUserPassword(ctx context.Context, username string, passwordHash string) (err error) {
+	ctx, done := s.start(ctx, "UpdateUserPassword")
+	defer func() { done(err) }()
+	return s.store.UpdateUserPassword(ctx, username, passwordHash)
+}
+
+func (s *instrumentedStore) DeleteUser(ctx context.Context, username string) (err error) {
+	ctx, done := s.start(ctx, "DeleteUser")
+	defer func() { done(err) }()
+	return s.store.DeleteUser(ctx, username)
+}
+
+func (s *instrumentedStore) AddSession(ctx context.Context, userID uuid.UUID, hash []byte, expiresAt time.Time) (err error) {
+	ctx, done := s.start(ctx, "AddSession")
+	defer func() { done(err) }()
+	return s.store.AddSession(ctx, userID, hash, expiresAt)
+}
+
+func (s *instrumentedStore) SessionUser(ctx context.Context, hash []byte) (_ User, err error) {
+	ctx, done := s.start(ctx, "SessionUser")
+	defer func() { done(err) }()
+	return s.store.SessionUser(ctx, hash)
+}
+
+func (s *instrumentedStore) DeleteSession(ctx context.Context, hash []byte) (err error) {
+	ctx, done := s.start(ctx, "DeleteSession")
+	defer func() { done(err) }()
+	return s.store.DeleteSession(ctx, hash)
+}
+
+func (s *instrumentedStore) CleanSessions(ctx context.Context) (_ int, err error) {
+	ctx, done := s.start(ctx, "CleanSessions")
+	defer func() { done(err) }()
+	return s.store.CleanSessions(ctx)
+}
+
+func (s *instrumentedStore) AddMetaInfo(ctx context.Context, torrentID uuid.UUID, m *metainfo.MetaInfo, uploadedBy string) (_ Torrent, err error) {
+	ctx, done := s.start(ctx, "AddMetaInfo", torrentIDAttr(torrentID))
+	defer func() { done(err) }()
+	return s.store.AddMetaInfo(ctx, torrentID, m, uploadedBy)
+}
+
+func (s *instrumentedStore) MetaInfo(ctx context.Context, torrentID uuid.UUID) (_ TorrentMetaInfo, err error) {
+	ctx, done := s.start(ctx, "MetaInfo", torrentIDAttr(torrentID))
+	defer func() { done(err) }()
+	return s.store.MetaInfo(ctx, torrentID)
+}
+
+func (s *instrumentedStore) Stats(ctx context.Context) (_ Stats, err error) {
+	ctx, done := s.start(ctx, "Stats")
+	defer func() { done(err) }()
+	return s.store.Stats(ctx)
+}
+
+func (s *instrumentedStore) Ping(ctx context.Context) (_ bool, err error) {
+	ctx, done := s.start(ctx, "Ping")
+	defer func() { done(err) }()
+	return s.store.Ping(ctx)
+}
+
+func (s *instrumentedStore) MigrationVersion(ctx context.Context) (_ int64, _ bool, err error) {
+	ctx, done := s.start(ctx, "MigrationVersion")
+	defer func() { done(err) }()
+	return s.store.MigrationVersion(ctx)
+}
@@ -0,0 +1,73 @@
+package tracker
+
+import (
+	"context"
+
+	"github.com/gofrs/uuid"
+	pgx "github.com/jackc/pgx/v5"
+	"github.com/salimnassim/tracker/metainfo"
+)
+
+func (ts *torrentStore) AddMetaInfo(ctx context.Context, torrentID uuid.UUID, m *metainfo.MetaInfo, uploadedBy string) (Torrent, error) {
+	tx, err := ts.pool.Begin(ctx)
+	if err != nil {
+		return Torrent{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	query := `insert into torrent_metainfo (torrent_id, piece_length, pieces, private, files, info, extra, uploaded_by, created_at)
+	values ($1, $2, $3, $4, $5, $6, $7, $8, now())
+	on conflict (torrent_id) do update set
+		piece_length = excluded.piece_length,
+		pieces = excluded.pieces,
+		private = excluded.private,
+		files = excluded.files,
+		info = excluded.info,
+		extra = excluded.extra,
+		uploaded_by = excluded.uploaded_by,
+		created_at = excluded.created_at`
+
+	_, err = tx.Exec(ctx, query, torrentID, m.PieceLength, m.Pieces, m.Private, m.Files, m.Info, m.Extra, uploadedBy)
+	if err != nil {
+		return Torrent{}, err
+	}
+
+	query = `update torrents as t set name = $2, size = $3
+	where t.id = $1 and t.info_hash = $4
+	returning ` + torrentColumns
+
+	rows, err := tx.Query(ctx, query, torrentID, m.Name, m.Length, m.InfoHash[:])
+	if err != nil {
+		return Torrent{}, err
+	}
+	torrent, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[Torrent])
+	if err != nil {
+		return Torrent{}, err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return Torrent{}, err
+	}
+
+	return torrent, nil
+}
+
+func (ts *torrentStore) MetaInfo(ctx context.Context, torrentID uuid.UUID) (TorrentMetaInfo, error) {
+	query := `select torrent_id, piece_length, pieces, private, files, info, extra, uploaded_by, created_at
+	from torrent_metainfo
+	where torrent_id = $1`
+
+	rows, err := ts.pool.Query(ctx, query, torrentID)
+	if err != nil {
+		return TorrentMetaInfo{}, err
+	}
+	defer rows.Close()
+
+	m, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[TorrentMetaInfo])
+	if err != nil {
+		return TorrentMetaInfo{}, err
+	}
+
+	return m, nil
+}
@@ -0,0 +1,182 @@
+package tracker
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/gofrs/uuid"
+	pgx "github.com/jackc/pgx/v5"
+)
+
+func (ts *torrentStore) SwarmActivity(ctx context.Context, infoHash []byte, from time.Time, step string) ([]SwarmActivity, error) {
+	query := `select date_trunc($3, created_at, 'UTC') as at,
+		count(distinct peer_id) filter (where "left" = 0) as seeders,
+		count(distinct peer_id) filter (where "left" > 0) as leechers,
+		count(*) filter (where event = 'completed') as completed
+	from announce_log
+	where info_hash = $1 and created_at >= $2
+	group by 1
+	order by 1`
+
+	rows, err := ts.pool.Query(ctx, query, infoHash, from, step)
+	if err != nil {
+		return nil, err
+	}
+
+	activity, err := pgx.CollectRows(rows, pgx.RowToStructByName[SwarmActivity])
+	if err != nil {
+		return nil, err
+	}
+
+	return activity, nil
+}
+
+func (ts *torrentStore) PeerClients(ctx context.Context, torrentID uuid.UUID) ([]ClientCount, error) {
+	// the client and version are in the first 8 bytes of peer_id
+	query := `select substring(peer_id from 1 for 8) as prefix, count(*) as peers
+	from peers
+	where torrent_id = $1
+	group by 1`
+
+	rows, err := ts.pool.Query(ctx, query, torrentID)
+	if err != nil {
+		return nil, err
+	}
+
+	return collectClients(rows, true)
+}
+
+// Counts clients from rows of peer_id prefixes and peer counts.
+// Versions are merged unless withVersion is set.
+func collectClients(rows pgx.Rows, withVersion bool) ([]ClientCount, error) {
+	defer rows.Close()
+
+	counts := map[[2]string]int{}
+	for rows.Next() {
+		var prefix []byte
+		var peers int
+		err := rows.Scan(&prefix, &peers)
+		if err != nil {
+			return nil, err
+		}
+		name, version := ParseClient(prefix)
+		if !withVersion {
+			version = ""
+		}
+		counts[[2]string{name, version}] += peers
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	clients := []ClientCount{}
+	for k, peers := range counts {
+		clients = append(clients, ClientCount{Name: k[0], Version: k[1], Peers: peers})
+	}
+	slices.SortFunc(clients, func(a, b ClientCount) int {
+		if c := cmp.Compare(b.Peers, a.Peers); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Name+" "+a.Version, b.Name+" "+b.Version)
+	})
+
+	return clients, nil
+}
+
+func (ts *torrentStore) SnapshotSwarms(ctx context.Context, at time.Time) (int, error) {
+	// the run is recorded even if no torrent has peers, so rollups count it
+	query := `with run as (
+		insert into swarm_snapshot_runs (resolution, at)
+		values ('raw', $1)
+		on conflict do nothing
+	)
+	insert into swarm_snapshots (torrent_id, resolution, at, seeders, leechers, completed)
+	select id, 'raw', $1, seeders, leechers, completed
+	from torrents
+	where seeders > 0 or leechers > 0
+	on conflict (torrent_id, resolution, at) do nothing`
+
+	tag, err := ts.pool.Exec(ctx, query, at)
+	if err != nil {
+		return 0, err
+	}
+
+	return int(tag.RowsAffected()), nil
+}
+
+func (ts *torrentStore) RollupSnapshots(ctx context.Context, resolution string, before time.Time) (int, error) {
+	source := map[string]string{SnapshotHour: SnapshotRaw, SnapshotDay: SnapshotHour}[resolution]
+	if source == "" {
+		return 0, fmt.Errorf("cant roll up snapshots into %q", resolution)
+	}
+
+	// only complete intervals after the last rolled up one
+	// torrents without peers are not snapshotted, so a torrent missing from
+	// a snapshot of the interval counts as zero and sums are divided by the
+	// number of runs in the interval, which are recorded even when no
+	// torrent had peers. the rolled up interval is recorded as a run too.
+	query := `with runs as (
+		select date_trunc($1, at, 'UTC') as bucket, count(*) as n
+		from swarm_snapshot_runs
+		where resolution = $2
+			and at < date_trunc($1, $3::timestamptz, 'UTC')
+			and at >= coalesce(
+				(select max(at) from swarm_snapshot_runs where resolution = $1) + ('1 ' || $1)::interval,
+				'-infinity')
+		group by 1
+	), run as (
+		insert into swarm_snapshot_runs (resolution, at)
+		select $1, bucket from runs
+		on conflict do nothing
+	)
+	insert into swarm_snapshots (torrent_id, resolution, at, seeders, leechers, completed)
+	select s.torrent_id, $1, r.bucket, round(sum(s.seeders)::numeric / r.n), round(sum(s.leechers)::numeric / r.n), max(s.completed)
+	from swarm_snapshots s
+	join runs r on r.bucket = date_trunc($1, s.at, 'UTC')
+	where s.resolution = $2 and s.at >= (select min(bucket) from runs)
+	group by s.torrent_id, r.bucket, r.n
+	on conflict (torrent_id, resolution, at) do nothing`
+
+	tag, err := ts.pool.Exec(ctx, query, resolution, source, before)
+	if err != nil {
+		return 0, err
+	}
+
+	return int(tag.RowsAffected()), nil
+}
+
+func (ts *torrentStore) DropSnapshots(ctx context.Context, resolution string, before time.Time) (int, error) {
+	query := `with runs as (
+		delete from swarm_snapshot_runs where resolution = $1 and at < $2
+	)
+	delete from swarm_snapshots where resolution = $1 and at < $2`
+
+	tag, err := ts.pool.Exec(ctx, query, resolution, before)
+	if err != nil {
+		return 0, err
+	}
+
+	return int(tag.RowsAffected()), nil
+}
+
+func (ts *torrentStore) SwarmHistory(ctx context.Context, torrentID uuid.UUID, resolution string, from time.Time) ([]SwarmSnapshot, error) {
+	query := `select at, seeders, leechers, completed
+	from swarm_snapshots
+	where torrent_id = $1 and resolution = $2 and at >= $3
+	order by at`
+
+	rows, err := ts.pool.Query(ctx, query, torrentID, resolution, from)
+	if err != nil {
+		return nil, err
+	}
+
+	history, err := pgx.CollectRows(rows, pgx.RowToStructByName[SwarmSnapshot])
+	if err != nil {
+		return nil, err
+	}
+
+	return history, nil
+}
@@ -0,0 +1,131 @@
+package tracker
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestSwarmSnapshots(t *testing.T) {
+	server := newTestServer(t)
+	ctx := context.Background()
+	store := server.store
+
+	infoHash := make([]byte, 20)
+	_, err := rand.Read(infoHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	torrent, _, err := store.GetOrAddTorrent(ctx, infoHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.DeleteTorrent(ctx, torrent.ID) })
+
+	announce := func(i int, left int) {
+		err := store.UpsertPeer(ctx, torrent.ID, AnnounceRequest{
+			InfoHash: infoHash,
+			PeerID:   []byte(fmt.Sprintf("-TR3000-%012d", i)),
+			IP:       "127.0.0.1",
+			Port:     6881 + i,
+			Key:      fmt.Sprint(i),
+			Left:     left,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	hour := time.Now().UTC().Truncate(time.Hour).Add(-3 * time.Hour)
+	t.Cleanup(func() { server.pool.Exec(ctx, `delete from swarm_snapshot_runs where at >= $1`, hour) })
+	snapshot := func(at time.Time) {
+		_, err := store.SnapshotSwarms(ctx, at)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// a torrent whose peers leave between snapshots
+	gone := newTestTorrent(t, server)
+	for i := 0; i < 2; i++ {
+		err := store.UpsertPeer(ctx, gone.ID, AnnounceRequest{
+			InfoHash: gone.InfoHash,
+			PeerID:   []byte(fmt.Sprintf("-TR3000-%012d", i)),
+			IP:       "127.0.0.1",
+			Port:     6881 + i,
+			Left:     0,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	announce(0, 0)
+	snapshot(hour.Add(5 * time.Minute))
+	announce(1, 100)
+	announce(2, 100)
+	announce(3, 0)
+	_, err = server.pool.Exec(ctx, `delete from peers where torrent_id = $1`, gone.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	snapshot(hour.Add(10 * time.Minute))
+	// a run where no torrent had peers
+	_, err = server.pool.Exec(ctx, `insert into swarm_snapshot_runs (resolution, at) values ('raw', $1)`, hour.Add(15*time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	snapshot(hour.Add(65 * time.Minute))
+
+	raw, err := store.SwarmHistory(ctx, torrent.ID, SnapshotRaw, hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(raw) != 3 || raw[0].Seeders != 1 || raw[0].Leechers != 0 || raw[1].Seeders != 2 || raw[1].Leechers != 2 {
+		t.Fatalf("unexpected raw snapshots %+v", raw)
+	}
+
+	_, err = store.RollupSnapshots(ctx, SnapshotHour, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	hourly, err := store.SwarmHistory(ctx, torrent.ID, SnapshotHour, hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hourly) != 2 || !hourly[0].At.Equal(hour) || hourly[0].Seeders != 1 || hourly[0].Leechers != 1 {
+		t.Fatalf("unexpected hourly snapshots %+v", hourly)
+	}
+
+	// the missing snapshots count as zero peers
+	hourly, err = store.SwarmHistory(ctx, gone.ID, SnapshotHour, hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hourly) != 1 || hourly[0].Seeders != 1 {
+		t.Fatalf("want average of 2, 0 and 0 seeders, got %+v", hourly)
+	}
+
+	// rolling up again adds nothing
+	n, err := store.RollupSnapshots(ctx, SnapshotHour, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Errorf("want no new hourly snapshots, got %d", n)
+	}
+
+	_, err = store.DropSnapshots(ctx, SnapshotRaw, hour.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err = store.SwarmHistory(ctx, torrent.ID, SnapshotRaw, hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(raw) != 1 {
+		t.Errorf("want 1 raw snapshot after drop, got %d", len(raw))
+	}
+}
@@ -0,0 +1,93 @@
+package tracker
+
+import (
+	"context"
+
+	"github.com/gofrs/uuid"
+	pgx "github.com/jackc/pgx/v5"
+)
+
+const tokenColumns = "id, name, scope, created_at, last_used_at, revoked_at"
+
+func (ts *torrentStore) AddToken(ctx context.Context, name string, scope Scope, hash []byte) (APIToken, error) {
+	query := `insert into api_tokens (id, name, hash, scope, created_at)
+	values (gen_random_uuid(), $1, $2, $3, now())
+	returning ` + tokenColumns
+
+	rows, err := ts.pool.Query(ctx, query, name, hash, scope.String())
+	if err != nil {
+		return APIToken{}, err
+	}
+	defer rows.Close()
+
+	token, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[APIToken])
+	if err != nil {
+		return APIToken{}, err
+	}
+
+	return token, nil
+}
+
+func (ts *torrentStore) TokenByHash(ctx context.Context, hash []byte) (APIToken, error) {
+	query := `select ` + tokenColumns + `
+	from api_tokens
+	where hash = $1 and revoked_at is null`
+
+	rows, err := ts.pool.Query(ctx, query, hash)
+	if err != nil {
+		return APIToken{}, err
+	}
+	defer rows.Close()
+
+	token, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[APIToken])
+	if err != nil {
+		return APIToken{}, err
+	}
+
+	return token, nil
+}
+
+func (ts *torrentStore) TouchToken(ctx context.Context, tokenID uuid.UUID) error {
+	// at most once a minute so busy tokens don't write on every request
+	query := `update api_tokens set last_used_at = now()
+	where id = $1 and (last_used_at is null or last_used_at < now() - interval '1 minute')`
+
+	_, err := ts.pool.Exec(ctx, query, tokenID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (ts *torrentStore) RevokeToken(ctx context.Context, tokenID uuid.UUID) error {
+	query := `update api_tokens set revoked_at = now() where id = $1 and revoked_at is null`
+
+	tag, err := ts.pool.Exec(ctx, query, tokenID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	return nil
+}
+
+func (ts *torrentStore) Tokens(ctx context.Context) ([]APIToken, error) {
+	query := `select ` + tokenColumns + `
+	from api_tokens
+	order by created_at`
+
+	rows, err := ts.pool.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	tokens, err := pgx.CollectRows(rows, pgx.RowToStructByName[APIToken])
+	if err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
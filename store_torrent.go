@@ -1,12 +1,22 @@
 package tracker
 
 import (
+	"bytes"
 	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
+	"unicode"
 
 	"github.com/gofrs/uuid"
 	pgx "github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/salimnassim/tracker/metainfo"
 )
 
 type TorrentStorable interface {
@@ -14,24 +24,212 @@ type TorrentStorable interface {
 	AddTorrent(ctx context.Context, infoHash []byte) (Torrent, error)
 	// Get torrent from store.
 	Torrent(ctx context.Context, infoHash []byte) (Torrent, error)
+	// Get torrent from store or add it if it does not exist.
+	// Safe to call concurrently for the same hash, created is true
+	// only for the caller that added the torrent.
+	GetOrAddTorrent(ctx context.Context, infoHash []byte) (torrent Torrent, created bool, err error)
 	// Increments torrentID completed property by one.
 	IncrementTorrent(ctx context.Context, torrentID uuid.UUID) error
-	// Get all torrents in store.
-	Torrents(ctx context.Context) ([]Torrent, error)
+	// Get torrent from store by ID.
+	TorrentByID(ctx context.Context, torrentID uuid.UUID) (Torrent, error)
+	// Get a page of torrents and, if query.Count is set, the total number of torrents.
+	// Hidden torrents are not included.
+	ListTorrents(ctx context.Context, query TorrentQuery) ([]Torrent, int, error)
+	// Get categories in use with their number of torrents.
+	Categories(ctx context.Context) ([]Category, error)
 	Scrape(ctx context.Context, hashes [][]byte) ([]Torrent, error)
 	// Get all peers for torrentID.
 	Peers(ctx context.Context, torrentID uuid.UUID) ([]Peer, error)
+	// Get a page of peers for torrentID.
+	ListPeers(ctx context.Context, torrentID uuid.UUID, limit int, offset int) ([]Peer, error)
 	// Try to update peer which already exist in the store.
 	// Operation success is denoted by bool.
 	UpdatePeerWithKey(ctx context.Context, torrentID uuid.UUID, req AnnounceRequest) (bool, error)
 	// Update or insert peer to store.
 	UpsertPeer(ctx context.Context, torrentID uuid.UUID, req AnnounceRequest) error
+	// Update or insert peers to store in a single batch.
+	// Peers with a key are updated by key first, like UpdatePeerWithKey.
+	UpsertPeers(ctx context.Context, updates []PeerUpdate) error
 	// Remove stale peers that have not announced in interval.
 	CleanPeers(ctx context.Context, interval time.Duration) (int, error)
+	// Recount seeders and leechers of torrents whose counters have drifted.
+	// Returns the number of torrents that were corrected.
+	ReconcileTorrents(ctx context.Context) (int, error)
 	// Log announce request.
 	Log(ctx context.Context, req AnnounceRequest) error
+	// Log announce requests in a single copy.
+	LogMany(ctx context.Context, logs []AnnounceLog) error
+	// Create daily announce log partitions for a number of days starting from.
+	// Rows already logged to the default partition for a day are moved to its partition.
+	// A day that fails does not stop the following days.
+	// Returns the number of partitions that exist for the range.
+	CreateLogPartitions(ctx context.Context, from time.Time, days int) (int, error)
+	// Drop announce log partitions and rows older than before.
+	// Returns the number of dropped partitions.
+	DropLogPartitions(ctx context.Context, before time.Time) (int, error)
+	// Search the announce log, newest first.
+	AnnounceLogs(ctx context.Context, filter AnnounceLogFilter) ([]AnnounceLog, error)
+	// Count the swarm of infoHash in the announce log since from per UTC step ("hour" or "day"), oldest first.
+	SwarmActivity(ctx context.Context, infoHash []byte, from time.Time, step string) ([]SwarmActivity, error)
+	// Count the peers of torrentID by client name and version, most peers first.
+	PeerClients(ctx context.Context, torrentID uuid.UUID) ([]ClientCount, error)
+	// Take a raw snapshot at of every torrent with peers and record the run.
+	// Returns the number of snapshots taken.
+	SnapshotSwarms(ctx context.Context, at time.Time) (int, error)
+	// Roll up the snapshots of the next finer resolution into complete hours or days before before.
+	// Torrents missing from a recorded run count as zero in the averages.
+	// Returns the number of snapshots added.
+	RollupSnapshots(ctx context.Context, resolution string, before time.Time) (int, error)
+	// Delete snapshots of resolution older than before.
+	// Returns the number of deleted snapshots.
+	DropSnapshots(ctx context.Context, resolution string, before time.Time) (int, error)
+	// Get the snapshots of torrentID at resolution since from, oldest first.
+	SwarmHistory(ctx context.Context, torrentID uuid.UUID, resolution string, from time.Time) ([]SwarmSnapshot, error)
+	// Delete torrent and its peers.
+	DeleteTorrent(ctx context.Context, torrentID uuid.UUID) error
+	// Set and unset flags of torrent.
+	UpdateTorrentFlags(ctx context.Context, torrentID uuid.UUID, set []string, unset []string) (Torrent, error)
+	// Set name, category and tags of torrent.
+	UpdateTorrentLabels(ctx context.Context, torrentID uuid.UUID, name string, category string, tags []string) (Torrent, error)
+	// Set completed count of torrent to zero.
+	ResetCompleted(ctx context.Context, torrentID uuid.UUID) error
+	// Remove a single peer of torrent.
+	// Returns the removed peer.
+	EvictPeer(ctx context.Context, torrentID uuid.UUID, peerID uuid.UUID) (Peer, error)
+	// Remove all peers announced from ip.
+	// Returns the removed peers.
+	EvictPeersByIP(ctx context.Context, ip string) ([]Peer, error)
+	// Record an administrative action.
+	AddAuditLog(ctx context.Context, entry AuditLog) error
+	// Search the audit log, newest first.
+	AuditLogs(ctx context.Context, filter AuditLogFilter) ([]AuditLog, error)
+	// Add API token with the hash of its secret.
+	AddToken(ctx context.Context, name string, scope Scope, hash []byte) (APIToken, error)
+	// Get token that is not revoked by the hash of its secret.
+	TokenByHash(ctx context.Context, hash []byte) (APIToken, error)
+	// Update last used time of token.
+	TouchToken(ctx context.Context, tokenID uuid.UUID) error
+	// Revoke token, revoked tokens are kept for reference.
+	RevokeToken(ctx context.Context, tokenID uuid.UUID) error
+	// Get all tokens including revoked ones.
+	Tokens(ctx context.Context) ([]APIToken, error)
+	// Add web UI user with a bcrypt password hash.
+	AddUser(ctx context.Context, username string, passwordHash string, scope Scope) (User, error)
+	// Add or update user of an identity provider by its issuer and subject.
+	// Returns ErrUsernameTaken if another user has the username.
+	UpsertSubjectUser(ctx context.Context, subject string, username string, scope Scope) (User, error)
+	// Get user by username.
+	UserByUsername(ctx context.Context, username string) (User, error)
+	// Get user by the passkey of their announce URL.
+	UserByPasskey(ctx context.Context, passkey string) (User, error)
+	// Replace the passkey of user with a new random one.
+	ResetPasskey(ctx context.Context, username string) (string, error)
+	// Change password of user and remove their sessions.
+	UpdateUserPassword(ctx context.Context, username string, passwordHash string) error
+	// Delete user and their sessions.
+	DeleteUser(ctx context.Context, username string) error
+	// Add session for user by the hash of its cookie.
+	AddSession(ctx context.Context, userID uuid.UUID, hash []byte, expiresAt time.Time) error
+	// Get user of a session that has not expired.
+	SessionUser(ctx context.Context, hash []byte) (User, error)
+	// Remove session.
+	DeleteSession(ctx context.Context, hash []byte) error
+	// Remove expired sessions.
+	CleanSessions(ctx context.Context) (int, error)
+	// Store parsed metainfo of torrent and set its name and size.
+	AddMetaInfo(ctx context.Context, torrentID uuid.UUID, m *metainfo.MetaInfo, uploadedBy string) (Torrent, error)
+	// Get stored metainfo of torrent.
+	MetaInfo(ctx context.Context, torrentID uuid.UUID) (TorrentMetaInfo, error)
+	// Get totals of the store. Hidden torrents and their peers are not counted.
+	// This scans peers and a day of the announce log,
+	// use the stats cached by the server instead.
+	Stats(ctx context.Context) (Stats, error)
 	// Test store connection.
 	Ping(ctx context.Context) (bool, error)
+	// Get the applied schema migration version and whether the last migration failed halfway.
+	MigrationVersion(ctx context.Context) (int64, bool, error)
+}
+
+// Columns of Torrent, torrents is aliased as t.
+const torrentColumns = "t.id, t.info_hash, t.completed, t.created_at, t.seeders, t.leechers, t.flags, t.name, t.size, t.category, t.tags, " +
+	"exists (select 1 from torrent_metainfo m where m.torrent_id = t.id) as uploaded"
+
+// Sortable torrent columns.
+var torrentSorts = []string{"created_at", "seeders", "leechers", "completed"}
+
+// Page of torrents sorted by Sort in Order (asc or desc).
+type TorrentQuery struct {
+	Sort  string
+	Order string
+	// No limit if zero.
+	Limit  int
+	Offset int
+
+	// Full text search of names in websearch syntax.
+	Search string
+	// Zero fields are not filtered on.
+	Category string
+	Tags     []string
+	// Only torrents with uploaded metainfo.
+	Uploaded bool
+
+	// Keyset pagination, only torrents after or before the cursor in sort order.
+	// Offset should be zero when a cursor is set.
+	After  *TorrentCursor
+	Before *TorrentCursor
+
+	// Count the torrents matching the filter, otherwise the total is zero.
+	Count bool
+}
+
+// Position of a torrent in a sort order.
+type TorrentCursor struct {
+	// Value of the sort column, unix microseconds for created_at.
+	Value int64
+	ID    uuid.UUID
+}
+
+// Returns the cursor of t in sort order.
+func NewTorrentCursor(sort string, t Torrent) *TorrentCursor {
+	c := &TorrentCursor{ID: t.ID}
+	switch sort {
+	case "created_at":
+		c.Value = t.CreatedAt.UnixMicro()
+	case "seeders":
+		c.Value = int64(t.Seeders)
+	case "leechers":
+		c.Value = int64(t.Leechers)
+	case "completed":
+		c.Value = int64(t.Completed)
+	}
+	return c
+}
+
+// Encodes the cursor for a query string.
+func (c *TorrentCursor) String() string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(c.Value, 10) + ":" + c.ID.String()))
+}
+
+// Decodes a cursor encoded by String.
+func ParseTorrentCursor(s string) (*TorrentCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	value, id, found := strings.Cut(string(b), ":")
+	if !found {
+		return nil, errors.New("cursor is not valid")
+	}
+	c := &TorrentCursor{}
+	c.Value, err = strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	c.ID, err = uuid.FromString(id)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
 }
 
 type torrentStore struct {
@@ -45,9 +243,9 @@ func NewTorrentStore(pool *pgxpool.Pool) *torrentStore {
 }
 
 func (ts *torrentStore) AddTorrent(ctx context.Context, infoHash []byte) (Torrent, error) {
-	query := `insert into torrents (id, info_hash, completed, created_at)
+	query := `insert into torrents as t (id, info_hash, completed, created_at)
 	values (gen_random_uuid(), $1, 0, now())
-	returning id, info_hash, completed, created_at, 0 as seeders, 0 as leechers`
+	returning ` + torrentColumns
 
 	rows, err := ts.pool.Query(ctx, query, infoHash)
 	if err != nil {
@@ -63,6 +261,41 @@ func (ts *torrentStore) AddTorrent(ctx context.Context, infoHash []byte) (Torren
 	return torrent, nil
 }
 
+func (ts *torrentStore) GetOrAddTorrent(ctx context.Context, infoHash []byte) (Torrent, bool, error) {
+	torrent, err := ts.Torrent(ctx, infoHash)
+	if err == nil {
+		return torrent, false, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return Torrent{}, false, err
+	}
+
+	// do update instead of do nothing so the row is returned even if
+	// another transaction inserted it after this statement started.
+	// xmax is zero only for rows inserted by this statement.
+	query := `insert into torrents as t (id, info_hash, completed, created_at)
+	values (gen_random_uuid(), $1, 0, now())
+	on conflict (info_hash) do update set info_hash = excluded.info_hash
+	returning ` + torrentColumns + `, (t.xmax = 0) as created`
+
+	rows, err := ts.pool.Query(ctx, query, infoHash)
+	if err != nil {
+		return Torrent{}, false, err
+	}
+	defer rows.Close()
+
+	type result struct {
+		Torrent
+		Created bool `db:"created"`
+	}
+	r, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[result])
+	if err != nil {
+		return Torrent{}, false, err
+	}
+
+	return r.Torrent, r.Created, nil
+}
+
 func (ts *torrentStore) IncrementTorrent(ctx context.Context, torrentID uuid.UUID) error {
 	query := `update torrents
 	set completed = completed + 1
@@ -96,6 +329,26 @@ func (ts *torrentStore) Peers(ctx context.Context, torrentID uuid.UUID) ([]Peer,
 	return peers, nil
 }
 
+func (ts *torrentStore) ListPeers(ctx context.Context, torrentID uuid.UUID, limit int, offset int) ([]Peer, error) {
+	query := `select id, torrent_id, peer_id, ip, port, uploaded, downloaded, "left", event, key, updated_at
+	from peers
+	where torrent_id = $1
+	order by updated_at desc, id
+	limit $2 offset $3`
+
+	rows, err := ts.pool.Query(ctx, query, torrentID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	peers, err := pgx.CollectRows(rows, pgx.RowToStructByName[Peer])
+	if err != nil {
+		return nil, err
+	}
+
+	return peers, nil
+}
+
 func (ts *torrentStore) UpdatePeerWithKey(ctx context.Context, torrentID uuid.UUID, req AnnounceRequest) (bool, error) {
 	query := `update peers set
 	peer_id = $1, ip = $2, port = $3, uploaded = $4, downloaded = $5, "left" = $6, event = $7, updated_at = now()
@@ -127,10 +380,47 @@ func (ts *torrentStore) UpsertPeer(ctx context.Context, torrentID uuid.UUID, req
 	return nil
 }
 
+func (ts *torrentStore) UpsertPeers(ctx context.Context, updates []PeerUpdate) error {
+	// update by key if the peer has one, otherwise upsert by peer id
+	query := `with updated as (
+		update peers set
+		peer_id = $2, ip = $3, port = $4, uploaded = $5, downloaded = $6, "left" = $7, event = $8, updated_at = $10
+		where torrent_id = $1 and key = $9 and $9 != ''
+		returning id
+	)
+	insert into peers (id, torrent_id, peer_id, ip, port, uploaded, downloaded, "left", event, key, updated_at)
+	select gen_random_uuid(), $1, $2, $3, $4, $5, $6, $7, $8, $9, $10
+	where not exists (select 1 from updated)
+	on conflict (torrent_id, peer_id) do update set
+	"left" = excluded."left", uploaded = excluded.uploaded, downloaded = excluded.downloaded,
+	updated_at = excluded.updated_at, event = excluded.event`
+
+	// lock rows in a stable order so concurrent batches can't deadlock
+	slices.SortFunc(updates, func(a, b PeerUpdate) int {
+		if c := bytes.Compare(a.TorrentID.Bytes(), b.TorrentID.Bytes()); c != 0 {
+			return c
+		}
+		return bytes.Compare(a.Request.PeerID, b.Request.PeerID)
+	})
+
+	batch := &pgx.Batch{}
+	for _, u := range updates {
+		req := u.Request
+		batch.Queue(query,
+			u.TorrentID, req.PeerID, req.IP, req.Port, req.Uploaded, req.Downloaded, req.Left, req.Event, req.Key,
+			u.UpdatedAt)
+	}
+
+	err := ts.pool.SendBatch(ctx, batch).Close()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
 func (ts *torrentStore) Torrent(ctx context.Context, infoHash []byte) (Torrent, error) {
-	query := `select t.id, t.info_hash, t.completed, t.created_at, 
-		(select count(*) from peers where peers.torrent_id = t.id and peers.left = 0) as seeders,
-		(select count(*) from peers where peers.torrent_id = t.id and peers.left != 0) as leechers
+	query := `select ` + torrentColumns + `
 	from torrents t
 	where t.info_hash = $1
 	limit 1`
@@ -150,31 +440,149 @@ func (ts *torrentStore) Torrent(ctx context.Context, infoHash []byte) (Torrent,
 	return torrent, nil
 }
 
-func (ts *torrentStore) Torrents(ctx context.Context) ([]Torrent, error) {
-	query := `select t.id, t.info_hash, t.completed, t.created_at,
-		(select count(*) from peers where peers.torrent_id = t.id and peers.left = 0) as seeders,
-		(select count(*) from peers where peers.torrent_id = t.id and peers.left != 0) as leechers
-	from torrents t`
+func (ts *torrentStore) TorrentByID(ctx context.Context, torrentID uuid.UUID) (Torrent, error) {
+	query := `select ` + torrentColumns + `
+	from torrents t
+	where t.id = $1`
 
-	var torrents []Torrent
-	rows, err := ts.pool.Query(ctx, query)
+	rows, err := ts.pool.Query(ctx, query, torrentID)
 	if err != nil {
-		return []Torrent{}, err
+		return Torrent{}, err
 	}
 	defer rows.Close()
 
-	torrents, err = pgx.CollectRows[Torrent](rows, pgx.RowToStructByName[Torrent])
+	torrent, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[Torrent])
 	if err != nil {
-		return []Torrent{}, err
+		return Torrent{}, err
 	}
 
-	return torrents, nil
+	return torrent, nil
+}
+
+// Splits search words on the separators the search column splits names on,
+// so "debian-12.iso" matches like it does in a name. A dash before a word
+// is kept as negation.
+func searchQuery(s string) string {
+	var b strings.Builder
+	prev := ' '
+	for _, r := range s {
+		if r == '.' || r == '_' || (r == '-' && !unicode.IsSpace(prev)) {
+			b.WriteRune(' ')
+		} else {
+			b.WriteRune(r)
+		}
+		prev = r
+	}
+	return b.String()
+}
+
+func (ts *torrentStore) ListTorrents(ctx context.Context, q TorrentQuery) ([]Torrent, int, error) {
+	// sort and order are not parameters, only allow known values
+	if !slices.Contains(torrentSorts, q.Sort) {
+		return nil, 0, fmt.Errorf("unknown sort %q", q.Sort)
+	}
+	if q.Order != "asc" && q.Order != "desc" {
+		return nil, 0, fmt.Errorf("unknown order %q", q.Order)
+	}
+
+	where := []string{"not ('hidden' = any(t.flags))"}
+	var args []any
+	add := func(cond string, arg any) {
+		args = append(args, arg)
+		where = append(where, fmt.Sprintf(cond, len(args)))
+	}
+
+	if q.Search != "" {
+		add("t.search @@ websearch_to_tsquery('simple', $%d)", searchQuery(q.Search))
+	}
+	if q.Category != "" {
+		add("t.category = $%d", q.Category)
+	}
+	if len(q.Tags) > 0 {
+		add("t.tags @> $%d", q.Tags)
+	}
+	if q.Uploaded {
+		where = append(where, "exists (select 1 from torrent_metainfo m where m.torrent_id = t.id)")
+	}
+	filter := strings.Join(where, " and ")
+	filterArgs := args
+
+	// rows before a cursor are fetched in reverse and reversed back
+	order, cursor, reverse := q.Order, q.After, false
+	if q.Before != nil {
+		cursor, reverse = q.Before, true
+		order = map[string]string{"asc": "desc", "desc": "asc"}[q.Order]
+	}
+	if cursor != nil {
+		var value any = cursor.Value
+		if q.Sort == "created_at" {
+			value = time.UnixMicro(cursor.Value)
+		}
+		cmp := "<"
+		if order == "asc" {
+			cmp = ">"
+		}
+		args = append(args, value, cursor.ID)
+		where = append(where, fmt.Sprintf("(t.%s, t.id) %s ($%d, $%d)", q.Sort, cmp, len(args)-1, len(args)))
+	}
+
+	var limit any
+	if q.Limit > 0 {
+		limit = q.Limit
+	}
+
+	query := fmt.Sprintf(`select `+torrentColumns+`
+	from torrents t
+	where %s
+	order by t.%s %s, t.id %s
+	limit $%d offset $%d`, strings.Join(where, " and "), q.Sort, order, order, len(args)+1, len(args)+2)
+
+	rows, err := ts.pool.Query(ctx, query, append(args, limit, q.Offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	torrents, err := pgx.CollectRows(rows, pgx.RowToStructByName[Torrent])
+	if err != nil {
+		return nil, 0, err
+	}
+	if reverse {
+		slices.Reverse(torrents)
+	}
+
+	var total int
+	if q.Count {
+		err = ts.pool.QueryRow(ctx, `select count(*) from torrents t where `+filter, filterArgs...).Scan(&total)
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+
+	return torrents, total, nil
+}
+
+func (ts *torrentStore) Categories(ctx context.Context) ([]Category, error) {
+	query := `select t.category as name, count(*) as torrents
+	from torrents t
+	where t.category != '' and not ('hidden' = any(t.flags))
+	group by t.category
+	order by t.category`
+
+	rows, err := ts.pool.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	categories, err := pgx.CollectRows(rows, pgx.RowToStructByName[Category])
+	if err != nil {
+		return nil, err
+	}
+
+	return categories, nil
 }
 
 func (ts *torrentStore) Scrape(ctx context.Context, hashes [][]byte) ([]Torrent, error) {
-	query := `select t.id, t.info_hash, t.completed, t.created_at,
-		(select count(*) from peers where peers.torrent_id = t.id and peers.left = 0) as seeders,
-		(select count(*) from peers where peers.torrent_id = t.id and peers.left != 0) as leechers
+	query := `select ` + torrentColumns + `
 	from torrents t
 	where t.info_hash = any($1)`
 
@@ -203,17 +611,111 @@ func (ts *torrentStore) CleanPeers(ctx context.Context, interval time.Duration)
 	return int(tag.RowsAffected()), nil
 }
 
+func (ts *torrentStore) ReconcileTorrents(ctx context.Context) (int, error) {
+	query := `update torrents t set seeders = c.seeders, leechers = c.leechers
+	from (
+		select t.id,
+			count(p.id) filter (where p.left = 0) as seeders,
+			count(p.id) filter (where p.left != 0) as leechers
+		from torrents t
+		left join peers p on p.torrent_id = t.id
+		group by t.id
+	) c
+	where t.id = c.id and (t.seeders != c.seeders or t.leechers != c.leechers)`
+
+	tag, err := ts.pool.Exec(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+
+	return int(tag.RowsAffected()), nil
+}
+
 func (ts *torrentStore) Log(ctx context.Context, req AnnounceRequest) error {
-	query := `insert into announce_log (id, info_hash, peer_id, event, ip, port, key, uploaded, downloaded, "left", created_at)
-	values (gen_random_uuid(), $1, $2, $3, $4, $5, $6, $7, $8, $9, now())`
+	query := `insert into announce_log (id, info_hash, peer_id, event, ip, port, key, uploaded, downloaded, "left", request_id, created_at)
+	values (gen_random_uuid(), $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, now())`
 
-	_, err := ts.pool.Exec(ctx, query, req.InfoHash, req.PeerID, req.Event, req.IP, req.Port, req.Key, req.Uploaded, req.Downloaded, req.Left)
+	_, err := ts.pool.Exec(ctx, query, req.InfoHash, req.PeerID, req.Event, req.IP, req.Port, req.Key, req.Uploaded, req.Downloaded, req.Left, RequestIDFromContext(ctx))
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
+func (ts *torrentStore) LogMany(ctx context.Context, logs []AnnounceLog) error {
+	columns := []string{"id", "info_hash", "peer_id", "event", "ip", "port", "key", "uploaded", "downloaded", "left", "request_id", "created_at"}
+
+	_, err := ts.pool.CopyFrom(ctx, pgx.Identifier{"announce_log"}, columns,
+		pgx.CopyFromSlice(len(logs), func(i int) ([]any, error) {
+			l := logs[i]
+			id, err := uuid.NewV4()
+			if err != nil {
+				return nil, err
+			}
+			ip, err := netip.ParseAddr(l.IP)
+			if err != nil {
+				return nil, err
+			}
+			return []any{id, l.InfoHash, l.PeerID, l.Event, ip, l.Port, l.Key, l.Uploaded, l.Downloaded, l.Left, l.RequestID, l.CreatedAt}, nil
+		}))
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (ts *torrentStore) Stats(ctx context.Context) (Stats, error) {
+	query := `with visible as (
+		select * from torrents where not ('hidden' = any(flags))
+	), visible_peers as (
+		select p.ip from peers p join visible t on t.id = p.torrent_id
+	)
+	select
+		count(*) as torrents,
+		(select count(*) from visible_peers) as peers,
+		coalesce(sum(seeders), 0) as seeders,
+		coalesce(sum(leechers), 0) as leechers,
+		coalesce(sum(completed), 0) as completed,
+		(select count(*) from visible_peers where family(ip) = 4) as ipv4_peers,
+		(select count(*) from visible_peers where family(ip) = 6) as ipv6_peers,
+		-- regular announces in the log are sampled, current peers add the IPs of
+		-- sessions that only sent regular announces in the last 24 hours
+		(select count(*) from (
+			select l.ip from announce_log l
+			join visible t on t.info_hash = l.info_hash
+			where l.created_at >= now() - interval '24 hours'
+			union
+			select ip from visible_peers
+		) ips) as unique_ips
+	from visible`
+
+	rows, err := ts.pool.Query(ctx, query)
+	if err != nil {
+		return Stats{}, err
+	}
+
+	stats, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[Stats])
+	if err != nil {
+		return Stats{}, err
+	}
+
+	rows, err = ts.pool.Query(ctx, `select substring(p.peer_id from 1 for 8) as prefix, count(*) as peers
+	from peers p
+	join torrents t on t.id = p.torrent_id
+	where not ('hidden' = any(t.flags))
+	group by 1`)
+	if err != nil {
+		return Stats{}, err
+	}
+
+	stats.Clients, err = collectClients(rows, false)
+	if err != nil {
+		return Stats{}, err
+	}
+
+	return stats, nil
+}
+
 func (ts *torrentStore) Ping(ctx context.Context) (bool, error) {
 	err := ts.pool.Ping(ctx)
 	if err != nil {
@@ -221,3 +723,16 @@ func (ts *torrentStore) Ping(ctx context.Context) (bool, error) {
 	}
 	return true, nil
 }
+
+func (ts *torrentStore) MigrationVersion(ctx context.Context) (int64, bool, error) {
+	// table of golang-migrate
+	query := `select version, dirty from schema_migrations limit 1`
+
+	var version int64
+	var dirty bool
+	err := ts.pool.QueryRow(ctx, query).Scan(&version, &dirty)
+	if err != nil {
+		return 0, false, err
+	}
+	return version, dirty, nil
+}
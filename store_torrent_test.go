@@ -0,0 +1,241 @@
+package tracker
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"os"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofrs/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/salimnassim/tracker/metainfo"
+)
+
+// Creates a server backed by the migrated database in TRACKER_TEST_DSN.
+// Skips the test if it is not set.
+func newTestServer(t *testing.T) *Server {
+	t.Helper()
+
+	dsn := os.Getenv("TRACKER_TEST_DSN")
+	if dsn == "" {
+		t.Skip("TRACKER_TEST_DSN is not set")
+	}
+
+	pool, err := pgxpool.New(context.Background(), dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(pool.Close)
+
+	return &Server{
+		config:    &ServerConfig{},
+		validator: validator.New(),
+		pool:      pool,
+		store:     NewTorrentStore(pool),
+		templates: NewTemplateStore(),
+	}
+}
+
+// Adds a torrent with a random info hash that is deleted after the test.
+func newTestTorrent(t *testing.T, server *Server) Torrent {
+	t.Helper()
+	ctx := context.Background()
+
+	infoHash := make([]byte, 20)
+	_, err := rand.Read(infoHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	torrent, err := server.store.AddTorrent(ctx, infoHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { server.store.DeleteTorrent(ctx, torrent.ID) })
+	return torrent
+}
+
+func TestTorrentCounters(t *testing.T) {
+	server := newTestServer(t)
+	ctx := context.Background()
+	store := server.store
+	torrent := newTestTorrent(t, server)
+
+	announce := func(i int, left int) {
+		t.Helper()
+		err := store.UpsertPeer(ctx, torrent.ID, AnnounceRequest{
+			InfoHash: torrent.InfoHash,
+			PeerID:   []byte(fmt.Sprintf("-TR3000-%012d", i)),
+			IP:       "127.0.0.1",
+			Port:     6881 + i,
+			Left:     left,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	expect := func(step string, seeders int, leechers int) {
+		t.Helper()
+		got, err := store.TorrentByID(ctx, torrent.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Seeders != seeders || got.Leechers != leechers {
+			t.Errorf("%s: want %d seeders and %d leechers, got %d and %d", step, seeders, leechers, got.Seeders, got.Leechers)
+		}
+	}
+
+	announce(0, 0)
+	announce(1, 100)
+	announce(2, 100)
+	expect("insert", 1, 2)
+
+	announce(1, 50)
+	expect("update without completing", 1, 2)
+	announce(1, 0)
+	expect("update to seeder", 2, 1)
+
+	_, err := server.pool.Exec(ctx, `delete from peers where torrent_id = $1 and peer_id = $2`, torrent.ID, []byte(fmt.Sprintf("-TR3000-%012d", 0)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect("delete", 1, 1)
+
+	_, err = server.pool.Exec(ctx, `update torrents set seeders = 40, leechers = 0 where id = $1`, torrent.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := store.ReconcileTorrents(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n < 1 {
+		t.Errorf("want drifted torrent reconciled, got %d", n)
+	}
+	expect("reconcile", 1, 1)
+}
+
+func TestTorrentUploaded(t *testing.T) {
+	server := newTestServer(t)
+	ctx := context.Background()
+	torrent := newTestTorrent(t, server)
+
+	// a name set by an admin does not register the torrent
+	category := fmt.Sprintf("test-%d", time.Now().UnixNano())
+	torrent, err := server.store.UpdateTorrentLabels(ctx, torrent.ID, "renamed", category, []string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if torrent.Uploaded {
+		t.Errorf("want renamed torrent not uploaded")
+	}
+	uploaded := func() int {
+		t.Helper()
+		torrents, _, err := server.store.ListTorrents(ctx, TorrentQuery{Sort: "created_at", Order: "desc", Category: category, Uploaded: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(torrents)
+	}
+	if n := uploaded(); n != 0 {
+		t.Errorf("want no uploaded torrents listed, got %d", n)
+	}
+
+	m := &metainfo.MetaInfo{Info: []byte("de"), Name: "file.iso", PieceLength: 1 << 14, Pieces: 1, Length: 1, Files: []metainfo.File{{Path: "file.iso", Length: 1}}}
+	copy(m.InfoHash[:], torrent.InfoHash)
+	torrent, err = server.store.AddMetaInfo(ctx, torrent.ID, m, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !torrent.Uploaded {
+		t.Errorf("want uploaded torrent after metainfo")
+	}
+	torrent, err = server.store.Torrent(ctx, torrent.InfoHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !torrent.Uploaded || torrent.Name != "file.iso" {
+		t.Errorf("unexpected torrent %+v", torrent)
+	}
+	if n := uploaded(); n != 1 {
+		t.Errorf("want the uploaded torrent listed, got %d", n)
+	}
+}
+
+func TestSearchQuery(t *testing.T) {
+	for q, want := range map[string]string{
+		"debian-12.iso":        "debian 12 iso",
+		"ubuntu -server":       "ubuntu -server",
+		`"linux_mint" or arch`: `"linux mint" or arch`,
+		"-beta foo-bar":        "-beta foo bar",
+	} {
+		if got := searchQuery(q); got != want {
+			t.Errorf("%q: want %q, got %q", q, want, got)
+		}
+	}
+}
+
+func TestListTorrentsCursor(t *testing.T) {
+	server := newTestServer(t)
+	ctx := context.Background()
+
+	// a category of its own keeps other torrents out of the pages
+	category := fmt.Sprintf("test-%d", time.Now().UnixNano())
+	var want []uuid.UUID
+	for i := 0; i < 5; i++ {
+		torrent := newTestTorrent(t, server)
+		_, err := server.store.UpdateTorrentLabels(ctx, torrent.ID, "", category, []string{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		// ties on seeders are ordered by id
+		_, err = server.pool.Exec(ctx, `update torrents set seeders = $2 where id = $1`, torrent.ID, i/2)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	all, total, err := server.store.ListTorrents(ctx, TorrentQuery{Sort: "seeders", Order: "desc", Category: category, Count: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 5 || total != 5 {
+		t.Fatalf("want 5 torrents, got %d of %d", len(all), total)
+	}
+	for _, torrent := range all {
+		want = append(want, torrent.ID)
+	}
+
+	var got []uuid.UUID
+	q := TorrentQuery{Sort: "seeders", Order: "desc", Category: category, Limit: 2}
+	for {
+		page, total, err := server.store.ListTorrents(ctx, q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if total != 0 {
+			t.Errorf("want no total without count, got %d", total)
+		}
+		if len(page) == 0 {
+			break
+		}
+		for _, torrent := range page {
+			got = append(got, torrent.ID)
+		}
+		q.After = NewTorrentCursor(q.Sort, page[len(page)-1])
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("want pages after cursors in order %v, got %v", want, got)
+	}
+
+	q = TorrentQuery{Sort: "seeders", Order: "desc", Category: category, Limit: 2, Before: NewTorrentCursor("seeders", all[4])}
+	page, _, err := server.store.ListTorrents(ctx, q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page) != 2 || page[0].ID != want[2] || page[1].ID != want[3] {
+		t.Errorf("want the page before the last torrent in order, got %+v", page)
+	}
+}
@@ -0,0 +1,198 @@
+package tracker
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/gofrs/uuid"
+	pgx "github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+const userColumns = "u.id, u.username, u.password_hash, u.scope, u.created_at, u.subject, u.passkey"
+
+func (ts *torrentStore) AddUser(ctx context.Context, username string, passwordHash string, scope Scope) (User, error) {
+	query := `insert into users as u (id, username, password_hash, scope, created_at)
+	values (gen_random_uuid(), $1, $2, $3, now())
+	returning ` + userColumns
+
+	rows, err := ts.pool.Query(ctx, query, username, passwordHash, scope.String())
+	if err != nil {
+		return User{}, err
+	}
+	defer rows.Close()
+
+	user, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[User])
+	if err != nil {
+		return User{}, err
+	}
+
+	return user, nil
+}
+
+func (ts *torrentStore) UpsertSubjectUser(ctx context.Context, subject string, username string, scope Scope) (User, error) {
+	// users of an identity provider have no password
+	query := `insert into users as u (id, username, password_hash, scope, created_at, subject)
+	values (gen_random_uuid(), $2, '', $3, now(), $1)
+	on conflict (subject) do update set username = excluded.username, scope = excluded.scope
+	returning ` + userColumns
+
+	rows, err := ts.pool.Query(ctx, query, subject, username, scope.String())
+	if err != nil {
+		return User{}, err
+	}
+	defer rows.Close()
+
+	user, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[User])
+	// a local user or another subject has the username
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.ConstraintName == "users_username_key" {
+		return User{}, ErrUsernameTaken
+	}
+	if err != nil {
+		return User{}, err
+	}
+
+	return user, nil
+}
+
+func (ts *torrentStore) UserByUsername(ctx context.Context, username string) (User, error) {
+	query := `select ` + userColumns + `
+	from users u
+	where u.username = $1`
+
+	rows, err := ts.pool.Query(ctx, query, username)
+	if err != nil {
+		return User{}, err
+	}
+	defer rows.Close()
+
+	user, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[User])
+	if err != nil {
+		return User{}, err
+	}
+
+	return user, nil
+}
+
+func (ts *torrentStore) UserByPasskey(ctx context.Context, passkey string) (User, error) {
+	query := `select ` + userColumns + `
+	from users u
+	where u.passkey = $1`
+
+	rows, err := ts.pool.Query(ctx, query, passkey)
+	if err != nil {
+		return User{}, err
+	}
+	defer rows.Close()
+
+	user, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[User])
+	if err != nil {
+		return User{}, err
+	}
+
+	return user, nil
+}
+
+func (ts *torrentStore) ResetPasskey(ctx context.Context, username string) (string, error) {
+	query := `update users set passkey = replace(gen_random_uuid()::text, '-', '')
+	where username = $1
+	returning passkey`
+
+	var passkey string
+	err := ts.pool.QueryRow(ctx, query, username).Scan(&passkey)
+	if err != nil {
+		return "", err
+	}
+
+	return passkey, nil
+}
+
+func (ts *torrentStore) UpdateUserPassword(ctx context.Context, username string, passwordHash string) error {
+	query := `update users set password_hash = $2 where username = $1`
+
+	tag, err := ts.pool.Exec(ctx, query, username, passwordHash)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	// sign out everywhere after a password change
+	_, err = ts.pool.Exec(ctx, `delete from sessions s using users u where s.user_id = u.id and u.username = $1`, username)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (ts *torrentStore) DeleteUser(ctx context.Context, username string) error {
+	query := `delete from users where username = $1`
+
+	tag, err := ts.pool.Exec(ctx, query, username)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	return nil
+}
+
+func (ts *torrentStore) AddSession(ctx context.Context, userID uuid.UUID, hash []byte, expiresAt time.Time) error {
+	query := `insert into sessions (id, hash, user_id, created_at, expires_at)
+	values (gen_random_uuid(), $1, $2, now(), $3)`
+
+	_, err := ts.pool.Exec(ctx, query, hash, userID, expiresAt)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (ts *torrentStore) SessionUser(ctx context.Context, hash []byte) (User, error) {
+	query := `select ` + userColumns + `
+	from sessions s
+	join users u on u.id = s.user_id
+	where s.hash = $1 and s.expires_at > now()`
+
+	rows, err := ts.pool.Query(ctx, query, hash)
+	if err != nil {
+		return User{}, err
+	}
+	defer rows.Close()
+
+	user, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[User])
+	if err != nil {
+		return User{}, err
+	}
+
+	return user, nil
+}
+
+func (ts *torrentStore) DeleteSession(ctx context.Context, hash []byte) error {
+	query := `delete from sessions where hash = $1`
+
+	_, err := ts.pool.Exec(ctx, query, hash)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (ts *torrentStore) CleanSessions(ctx context.Context) (int, error) {
+	query := `delete from sessions where expires_at < now()`
+
+	tag, err := ts.pool.Exec(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+
+	return int(tag.RowsAffected()), nil
+}
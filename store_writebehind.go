@@ -0,0 +1,381 @@
+package tracker
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/rs/zerolog/log"
+	"github.com/salimnassim/tracker/metric"
+)
+
+type WriteBehindConfig struct {
+	// How often pending writes are flushed. Zero disables write-behind.
+	FlushInterval time.Duration
+	// Flush early once this many writes are pending.
+	FlushSize int
+	// Writers wait for a flush once this many writes are pending.
+	QueueSize int
+}
+
+const (
+	// Number of flushes in a row a failed batch of announce logs is kept for.
+	logFlushAttempts = 3
+	// Flushes time out after the flush interval, but not sooner than this.
+	minFlushTimeout = time.Second
+)
+
+type peerKey struct {
+	torrentID uuid.UUID
+	peerID    string
+}
+
+// Wraps a TorrentStorable and buffers peer updates and announce logs.
+// Peer updates are coalesced per torrent and peer, so only the latest
+// announce of a peer is written. Everything else is passed through.
+type writeBehindStore struct {
+	TorrentStorable
+	config WriteBehindConfig
+
+	mu     *sync.Mutex
+	peers  map[peerKey]PeerUpdate
+	logs   []AnnounceLog
+	closed bool
+	// flushes of announce logs that failed in a row
+	logFailures int
+	drained     chan struct{}
+	// held while a batch is taken from the queue and written,
+	// so evictions can't interleave with a flush
+	flushing *sync.Mutex
+
+	flush chan struct{}
+	stop  chan struct{}
+	done  chan struct{}
+}
+
+// Create new write-behind store around store and start flushing.
+func NewWriteBehindStore(store TorrentStorable, config WriteBehindConfig) *writeBehindStore {
+	wb := &writeBehindStore{
+		TorrentStorable: store,
+		config:          config,
+		mu:              &sync.Mutex{},
+		flushing:        &sync.Mutex{},
+		peers:           make(map[peerKey]PeerUpdate),
+		drained:         make(chan struct{}),
+		flush:           make(chan struct{}, 1),
+		stop:            make(chan struct{}),
+		done:            make(chan struct{}),
+	}
+	go wb.run()
+	return wb
+}
+
+// Queues the peer update and reports it as updated, so the caller does not
+// fall back to UpsertPeer. The key is resolved when the update is flushed:
+// UpsertPeers updates the peer by key first and by peer id otherwise,
+// which is the same as the fallback.
+func (wb *writeBehindStore) UpdatePeerWithKey(ctx context.Context, torrentID uuid.UUID, req AnnounceRequest) (bool, error) {
+	err := wb.UpsertPeer(ctx, torrentID, req)
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (wb *writeBehindStore) UpsertPeer(ctx context.Context, torrentID uuid.UUID, req AnnounceRequest) error {
+	update := PeerUpdate{
+		TorrentID: torrentID,
+		Request:   req,
+		UpdatedAt: time.Now(),
+	}
+	key := peerKey{torrentID: torrentID, peerID: string(req.PeerID)}
+
+	err := wb.wait(ctx, func() bool {
+		_, ok := wb.peers[key]
+		return ok
+	})
+	if err != nil {
+		return err
+	}
+	if wb.closed {
+		wb.mu.Unlock()
+		return wb.TorrentStorable.UpsertPeers(ctx, []PeerUpdate{update})
+	}
+
+	wb.peers[key] = update
+	wb.queued()
+	wb.mu.Unlock()
+	return nil
+}
+
+func (wb *writeBehindStore) Log(ctx context.Context, req AnnounceRequest) error {
+	entry := AnnounceLog{
+		AnnounceRequest: req,
+		RequestID:       RequestIDFromContext(ctx),
+		CreatedAt:       time.Now(),
+	}
+
+	err := wb.wait(ctx, func() bool { return false })
+	if err != nil {
+		return err
+	}
+	if wb.closed {
+		wb.mu.Unlock()
+		return wb.TorrentStorable.LogMany(ctx, []AnnounceLog{entry})
+	}
+
+	wb.logs = append(wb.logs, entry)
+	wb.queued()
+	wb.mu.Unlock()
+	return nil
+}
+
+// Evicts the peer and drops its queued update, so the next flush does not add it back.
+func (wb *writeBehindStore) EvictPeer(ctx context.Context, torrentID uuid.UUID, peerID uuid.UUID) (Peer, error) {
+	wb.flushing.Lock()
+	defer wb.flushing.Unlock()
+
+	peer, err := wb.TorrentStorable.EvictPeer(ctx, torrentID, peerID)
+	if err != nil {
+		return Peer{}, err
+	}
+
+	wb.dropPeers(func(u PeerUpdate) bool {
+		return evicted(u, peer)
+	})
+	return peer, nil
+}
+
+// Evicts the peers of ip and drops queued updates from ip or of the evicted peers.
+func (wb *writeBehindStore) EvictPeersByIP(ctx context.Context, ip string) ([]Peer, error) {
+	wb.flushing.Lock()
+	defer wb.flushing.Unlock()
+
+	peers, err := wb.TorrentStorable.EvictPeersByIP(ctx, ip)
+	if err != nil {
+		return nil, err
+	}
+
+	addr := net.ParseIP(ip)
+	wb.dropPeers(func(u PeerUpdate) bool {
+		if addr != nil && addr.Equal(net.ParseIP(u.Request.IP)) {
+			return true
+		}
+		for _, p := range peers {
+			if evicted(u, p) {
+				return true
+			}
+		}
+		return false
+	})
+	return peers, nil
+}
+
+// Number of writes waiting to be flushed.
+func (wb *writeBehindStore) Pending() int {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+	return wb.pending()
+}
+
+// Stops flushing on an interval and writes everything that is pending.
+// Writes after Close go directly to the wrapped store.
+func (wb *writeBehindStore) Close(ctx context.Context) error {
+	wb.mu.Lock()
+	if wb.closed {
+		wb.mu.Unlock()
+		return nil
+	}
+	wb.closed = true
+	wb.mu.Unlock()
+
+	close(wb.stop)
+	select {
+	case <-wb.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (wb *writeBehindStore) pending() int {
+	return len(wb.peers) + len(wb.logs)
+}
+
+// Locks the store and waits until there is room in the queue.
+// coalesced reports whether the write replaces one already queued.
+// The lock is held when wait returns without an error.
+func (wb *writeBehindStore) wait(ctx context.Context, coalesced func() bool) error {
+	wb.mu.Lock()
+	blocked := false
+	for !wb.closed && wb.pending() >= wb.config.QueueSize && !coalesced() {
+		if !blocked {
+			blocked = true
+			metric.TrackerWriteBehindBlocked.Inc()
+		}
+		drained := wb.drained
+		wb.mu.Unlock()
+		wb.kick()
+
+		select {
+		case <-drained:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		wb.mu.Lock()
+	}
+	return nil
+}
+
+// Removes queued peer updates matching drop.
+func (wb *writeBehindStore) dropPeers(drop func(u PeerUpdate) bool) {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+
+	for key, u := range wb.peers {
+		if drop(u) {
+			delete(wb.peers, key)
+		}
+	}
+	metric.TrackerWriteBehindQueue.WithLabelValues("peers").Set(float64(len(wb.peers)))
+}
+
+// Reports whether the queued update u would write the evicted peer p,
+// either by its peer id or by its key.
+func evicted(u PeerUpdate, p Peer) bool {
+	if u.TorrentID != p.TorrentID {
+		return false
+	}
+	return bytes.Equal(u.Request.PeerID, p.PeerID) || (p.Key != "" && u.Request.Key == p.Key)
+}
+
+// Updates queue metrics and kicks a flush if the queue is over the flush size.
+// Must be called with the lock held.
+func (wb *writeBehindStore) queued() {
+	metric.TrackerWriteBehindQueue.WithLabelValues("peers").Set(float64(len(wb.peers)))
+	metric.TrackerWriteBehindQueue.WithLabelValues("log").Set(float64(len(wb.logs)))
+	if wb.pending() >= wb.config.FlushSize {
+		wb.kick()
+	}
+}
+
+// Requests a flush without blocking.
+func (wb *writeBehindStore) kick() {
+	select {
+	case wb.flush <- struct{}{}:
+	default:
+	}
+}
+
+func (wb *writeBehindStore) run() {
+	defer close(wb.done)
+
+	ticker := time.NewTicker(wb.config.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-wb.flush:
+		case <-wb.stop:
+			wb.write()
+			return
+		}
+		wb.write()
+	}
+}
+
+// Takes everything pending from the queue and writes it to the wrapped store.
+func (wb *writeBehindStore) write() {
+	wb.flushing.Lock()
+	defer wb.flushing.Unlock()
+
+	wb.mu.Lock()
+	peers := make([]PeerUpdate, 0, len(wb.peers))
+	for _, u := range wb.peers {
+		peers = append(peers, u)
+	}
+	logs := wb.logs
+	wb.peers = make(map[peerKey]PeerUpdate)
+	wb.logs = nil
+	metric.TrackerWriteBehindQueue.WithLabelValues("peers").Set(0)
+	metric.TrackerWriteBehindQueue.WithLabelValues("log").Set(0)
+	// wake up writers waiting for room
+	close(wb.drained)
+	wb.drained = make(chan struct{})
+	wb.mu.Unlock()
+
+	if len(peers) == 0 && len(logs) == 0 {
+		return
+	}
+
+	start := time.Now()
+	defer func() {
+		metric.TrackerWriteBehindFlushSeconds.Observe(time.Since(start).Seconds())
+	}()
+
+	// flushes are not tied to a request, so they use their own context
+	// with a deadline so a stuck database does not hold up the queue forever
+	ctx, cancel := context.WithTimeout(context.Background(), max(wb.config.FlushInterval, minFlushTimeout))
+	defer cancel()
+
+	if len(logs) > 0 {
+		err := wb.TorrentStorable.LogMany(ctx, logs)
+		if err != nil {
+			log.Error().Err(err).Str("source", "write_behind").Msgf("cant flush %d announce logs", len(logs))
+			wb.requeueLogs(logs)
+		} else {
+			wb.mu.Lock()
+			wb.logFailures = 0
+			wb.mu.Unlock()
+			metric.TrackerWriteBehindFlushed.WithLabelValues("log").Add(float64(len(logs)))
+		}
+	}
+
+	if len(peers) > 0 {
+		err := wb.TorrentStorable.UpsertPeers(ctx, peers)
+		if err != nil {
+			// the batch is one transaction, retry peers one by one
+			// so a single bad update does not drop the rest
+			log.Warn().Err(err).Str("source", "write_behind").Msgf("cant flush %d peers in batch, retrying one by one", len(peers))
+			for _, u := range peers {
+				err := wb.TorrentStorable.UpsertPeers(ctx, []PeerUpdate{u})
+				if err != nil {
+					log.Error().Err(err).Str("source", "write_behind").Msg("cant flush peer")
+					metric.TrackerWriteBehindDropped.WithLabelValues("peers").Inc()
+					continue
+				}
+				metric.TrackerWriteBehindFlushed.WithLabelValues("peers").Inc()
+			}
+		} else {
+			metric.TrackerWriteBehindFlushed.WithLabelValues("peers").Add(float64(len(peers)))
+		}
+	}
+}
+
+// Puts announce logs that failed to flush back in front of the queue for the next flush.
+// Logs that do not fit in the queue are dropped, and so is everything
+// after logFlushAttempts failed flushes in a row or on the last flush.
+func (wb *writeBehindStore) requeueLogs(logs []AnnounceLog) {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+
+	wb.logFailures++
+	keep := 0
+	if !wb.closed && wb.logFailures < logFlushAttempts {
+		keep = min(len(logs), max(wb.config.QueueSize-wb.pending(), 0))
+	} else {
+		wb.logFailures = 0
+	}
+	if keep < len(logs) {
+		log.Error().Str("source", "write_behind").Msgf("dropped %d announce logs", len(logs)-keep)
+		metric.TrackerWriteBehindDropped.WithLabelValues("log").Add(float64(len(logs) - keep))
+	}
+
+	// not queued(), a failed batch should wait for the interval instead of flushing again right away
+	wb.logs = append(logs[:keep:keep], wb.logs...)
+	metric.TrackerWriteBehindQueue.WithLabelValues("log").Set(float64(len(wb.logs)))
+}
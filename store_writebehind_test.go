@@ -0,0 +1,267 @@
+package tracker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// Records batches written by the write-behind store.
+type recordingStore struct {
+	TorrentStorable
+	mu    sync.Mutex
+	peers []PeerUpdate
+	logs  []AnnounceLog
+}
+
+func (rs *recordingStore) UpsertPeers(ctx context.Context, updates []PeerUpdate) error {
+	rs.mu.Lock()
+	rs.peers = append(rs.peers, updates...)
+	rs.mu.Unlock()
+	return nil
+}
+
+func (rs *recordingStore) LogMany(ctx context.Context, logs []AnnounceLog) error {
+	rs.mu.Lock()
+	rs.logs = append(rs.logs, logs...)
+	rs.mu.Unlock()
+	return nil
+}
+
+func TestWriteBehindCoalesce(t *testing.T) {
+	ctx := context.Background()
+	rs := &recordingStore{}
+	wb := NewWriteBehindStore(rs, WriteBehindConfig{
+		FlushInterval: time.Hour,
+		FlushSize:     100,
+		QueueSize:     100,
+	})
+
+	torrentID := uuid.Must(uuid.NewV4())
+	req := AnnounceRequest{PeerID: []byte("-TR3000-dybw6lsnsc17"), IP: "127.0.0.1", Left: 10}
+	for i := 0; i < 3; i++ {
+		req.Left = 10 - i
+		err := wb.UpsertPeer(ctx, torrentID, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = wb.Log(ctx, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got, want := wb.Pending(), 4; got != want {
+		t.Errorf("want: %v pending, got %v", want, got)
+	}
+
+	err := wb.Close(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rs.peers) != 1 || rs.peers[0].Request.Left != 8 {
+		t.Errorf("want: one peer update with left 8, got %v", rs.peers)
+	}
+	if len(rs.logs) != 3 {
+		t.Errorf("want: 3 logs, got %v", len(rs.logs))
+	}
+}
+
+func TestWriteBehindBackpressure(t *testing.T) {
+	rs := &recordingStore{}
+	wb := NewWriteBehindStore(rs, WriteBehindConfig{
+		FlushInterval: time.Hour,
+		FlushSize:     100,
+		QueueSize:     2,
+	})
+	defer wb.Close(context.Background())
+
+	// a full queue kicks a flush, so writes keep succeeding
+	for i := 0; i < 10; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		err := wb.Log(ctx, AnnounceRequest{IP: "127.0.0.1"})
+		cancel()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if wb.Pending() > 2 {
+			t.Fatalf("want: at most 2 pending, got %v", wb.Pending())
+		}
+	}
+}
+
+// Fails the first failures calls to LogMany.
+type flakyLogStore struct {
+	recordingStore
+	failures int
+}
+
+func (fs *flakyLogStore) LogMany(ctx context.Context, logs []AnnounceLog) error {
+	fs.mu.Lock()
+	if fs.failures > 0 {
+		fs.failures--
+		fs.mu.Unlock()
+		return errors.New("copy failed")
+	}
+	fs.mu.Unlock()
+	return fs.recordingStore.LogMany(ctx, logs)
+}
+
+func TestWriteBehindLogRetry(t *testing.T) {
+	ctx := context.Background()
+	fs := &flakyLogStore{failures: 1}
+	wb := NewWriteBehindStore(fs, WriteBehindConfig{
+		FlushInterval: time.Hour,
+		FlushSize:     100,
+		QueueSize:     100,
+	})
+	defer wb.Close(ctx)
+
+	for i := 0; i < 3; i++ {
+		err := wb.Log(ctx, AnnounceRequest{IP: "127.0.0.1", Port: i})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the failed batch stays queued in front of newer logs
+	wb.write()
+	if got := wb.Pending(); got != 3 {
+		t.Fatalf("want: 3 pending after a failed flush, got %v", got)
+	}
+	err := wb.Log(ctx, AnnounceRequest{IP: "127.0.0.1", Port: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wb.write()
+	if len(fs.logs) != 4 || fs.logs[0].Port != 0 || fs.logs[3].Port != 3 {
+		t.Errorf("want: 4 logs in order, got %v", fs.logs)
+	}
+
+	// logs are dropped after failing logFlushAttempts flushes
+	fs.failures = logFlushAttempts
+	err = wb.Log(ctx, AnnounceRequest{IP: "127.0.0.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < logFlushAttempts; i++ {
+		wb.write()
+	}
+	if got := wb.Pending(); got != 0 || len(fs.logs) != 4 {
+		t.Errorf("want: failed log dropped, got %v pending and %v written", got, len(fs.logs))
+	}
+}
+
+// Blocks LogMany until the flush context is done.
+type stuckLogStore struct {
+	recordingStore
+	errs chan error
+}
+
+func (ss *stuckLogStore) LogMany(ctx context.Context, logs []AnnounceLog) error {
+	<-ctx.Done()
+	select {
+	case ss.errs <- ctx.Err():
+	default:
+	}
+	return ctx.Err()
+}
+
+func TestWriteBehindFlushTimeout(t *testing.T) {
+	ss := &stuckLogStore{errs: make(chan error, 1)}
+	wb := NewWriteBehindStore(ss, WriteBehindConfig{
+		FlushInterval: 10 * time.Millisecond,
+		FlushSize:     100,
+		QueueSize:     100,
+	})
+	defer wb.Close(context.Background())
+
+	err := wb.Log(context.Background(), AnnounceRequest{IP: "127.0.0.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case err := <-ss.errs:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("want: %v, got %v", context.DeadlineExceeded, err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("flush did not time out")
+	}
+}
+
+// Evicts peers from the recorded updates.
+type evictingStore struct {
+	recordingStore
+}
+
+func (es *evictingStore) EvictPeer(ctx context.Context, torrentID uuid.UUID, peerID uuid.UUID) (Peer, error) {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+	for i, u := range es.peers {
+		if u.TorrentID == torrentID {
+			es.peers = append(es.peers[:i], es.peers[i+1:]...)
+			return Peer{ID: peerID, TorrentID: torrentID, PeerID: u.Request.PeerID, Key: u.Request.Key}, nil
+		}
+	}
+	return Peer{}, pgx.ErrNoRows
+}
+
+func (es *evictingStore) EvictPeersByIP(ctx context.Context, ip string) ([]Peer, error) {
+	return nil, nil
+}
+
+func TestWriteBehindEvict(t *testing.T) {
+	ctx := context.Background()
+	es := &evictingStore{}
+	wb := NewWriteBehindStore(es, WriteBehindConfig{
+		FlushInterval: time.Hour,
+		FlushSize:     100,
+		QueueSize:     100,
+	})
+	defer wb.Close(ctx)
+
+	torrentID := uuid.Must(uuid.NewV4())
+	req := AnnounceRequest{PeerID: []byte("-TR3000-dybw6lsnsc17"), IP: "127.0.0.1", Key: "abc"}
+	err := wb.UpsertPeer(ctx, torrentID, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wb.write()
+
+	// queued again before the eviction, with a new peer id but the same key
+	req.PeerID = []byte("-TR3000-000000000000")
+	ok, err := wb.UpdatePeerWithKey(ctx, torrentID, req)
+	if err != nil || !ok {
+		t.Fatalf("want: queued update, got %v %v", ok, err)
+	}
+
+	_, err = wb.EvictPeer(ctx, torrentID, uuid.Must(uuid.NewV4()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := wb.Pending(); got != 0 {
+		t.Errorf("want: queued update of evicted peer dropped, got %v pending", got)
+	}
+
+	// queued updates from an evicted ip are dropped too
+	err = wb.UpsertPeer(ctx, torrentID, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = wb.EvictPeersByIP(ctx, "127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wb.write()
+	if len(es.peers) != 0 {
+		t.Errorf("want: no peers after eviction, got %v", es.peers)
+	}
+}
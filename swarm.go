@@ -0,0 +1,36 @@
+package tracker
+
+import "time"
+
+// Swarm activity of a torrent in an hour or day, counted from the announce log.
+// Seeders and leechers are distinct peers that announced in the interval.
+type SwarmActivity struct {
+	At        time.Time `db:"at"`
+	Seeders   int       `db:"seeders"`
+	Leechers  int       `db:"leechers"`
+	Completed int       `db:"completed"`
+}
+
+// Number of peers of a torrent running a client version.
+type ClientCount struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	Peers   int    `json:"peers"`
+}
+
+// Resolutions of swarm snapshots. Raw snapshots are rolled up into hours and hours into days.
+const (
+	SnapshotRaw  = "raw"
+	SnapshotHour = "hour"
+	SnapshotDay  = "day"
+)
+
+// Seeders, leechers and completed of a torrent at a point in time.
+// Hourly and daily snapshots have the average seeders and leechers
+// and the highest completed of the interval starting at At.
+type SwarmSnapshot struct {
+	At        time.Time `db:"at"`
+	Seeders   int       `db:"seeders"`
+	Leechers  int       `db:"leechers"`
+	Completed int       `db:"completed"`
+}
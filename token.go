@@ -0,0 +1,73 @@
+package tracker
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+// Access level of a token or user. Each scope includes the ones before it.
+type Scope int
+
+const (
+	// Read announce logs and other non-public data.
+	ScopeRead Scope = iota + 1
+	// Moderate torrents and peers.
+	ScopeModerate
+	// Everything.
+	ScopeAdmin
+)
+
+var scopeNames = map[Scope]string{
+	ScopeRead:     "read",
+	ScopeModerate: "moderate",
+	ScopeAdmin:    "admin",
+}
+
+func ParseScope(s string) (Scope, error) {
+	for scope, name := range scopeNames {
+		if name == s {
+			return scope, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown scope %q", s)
+}
+
+func (s Scope) String() string {
+	return scopeNames[s]
+}
+
+// Reports whether s grants required.
+func (s Scope) Allows(required Scope) bool {
+	return s >= required
+}
+
+// API token. Only the hash of the secret is stored.
+type APIToken struct {
+	ID         uuid.UUID  `db:"id"`
+	Name       string     `db:"name"`
+	Scope      string     `db:"scope"`
+	CreatedAt  time.Time  `db:"created_at"`
+	LastUsedAt *time.Time `db:"last_used_at"`
+	RevokedAt  *time.Time `db:"revoked_at"`
+}
+
+// Generates a new token secret and its hash.
+func NewTokenSecret() (string, []byte, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", nil, err
+	}
+	secret := "trk_" + base64.RawURLEncoding.EncodeToString(b)
+	return secret, HashTokenSecret(secret), nil
+}
+
+func HashTokenSecret(secret string) []byte {
+	hash := sha256.Sum256([]byte(secret))
+	return hash[:]
+}
@@ -3,9 +3,14 @@ package tracker
 import (
 	"encoding/json"
 	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/gofrs/uuid"
+	"github.com/salimnassim/tracker/metainfo"
 )
 
 type Torrent struct {
@@ -16,10 +21,125 @@ type Torrent struct {
 
 	Seeders  int `db:"seeders" json:"seeders"`
 	Leechers int `db:"leechers" json:"leechers"`
+
+	Flags []string `db:"flags" json:"flags"`
+
+	// Name and total size from the uploaded metainfo, empty until it is uploaded.
+	Name string `db:"name" json:"name"`
+	Size int64  `db:"size" json:"size"`
+	// Whether metainfo was uploaded. Admins can set a name without it, so only
+	// this registers a torrent in private mode.
+	Uploaded bool `db:"uploaded" json:"uploaded"`
+
+	Category string   `db:"category" json:"category"`
+	Tags     []string `db:"tags" json:"tags"`
+}
+
+type Category struct {
+	Name     string `db:"name" json:"name"`
+	Torrents int    `db:"torrents" json:"torrents"`
+}
+
+// Categories and tags are short lower case words.
+var labelPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,31}$`)
+
+// Validates a category or tag.
+func ValidLabel(s string) bool {
+	return labelPattern.MatchString(s)
+}
+
+// Splits comma separated tags, dropping empty ones and duplicates.
+func ParseTags(s string) []string {
+	tags := []string{}
+	for _, tag := range strings.Split(s, ",") {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag != "" && !slices.Contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// Metainfo of an uploaded .torrent file.
+type TorrentMetaInfo struct {
+	TorrentID   uuid.UUID       `db:"torrent_id"`
+	PieceLength int64           `db:"piece_length"`
+	Pieces      int             `db:"pieces"`
+	Private     bool            `db:"private"`
+	Files       []metainfo.File `db:"files"`
+	Info        []byte          `db:"info"`
+	Extra       []byte          `db:"extra"`
+	UploadedBy  string          `db:"uploaded_by"`
+	CreatedAt   time.Time       `db:"created_at"`
+}
+
+// Returns the name of the torrent, or its hex info hash if it has none.
+func (t Torrent) Title() string {
+	if t.Name != "" {
+		return t.Name
+	}
+	return fmt.Sprintf("%x", t.InfoHash)
+}
+
+// Returns a magnet link of the torrent with announce as the tracker.
+func (t Torrent) Magnet(announce string) string {
+	v := url.Values{}
+	if t.Name != "" {
+		v.Set("dn", t.Name)
+	}
+	if announce != "" {
+		v.Set("tr", announce)
+	}
+	magnet := fmt.Sprintf("magnet:?xt=urn:btih:%x", t.InfoHash)
+	if len(v) > 0 {
+		magnet += "&" + v.Encode()
+	}
+	return magnet
+}
+
+// Returns the size of the torrent in binary units, e.g. 1.5 GiB.
+func (t Torrent) SizeText() string {
+	return FormatSize(t.Size)
+}
+
+// Formats n bytes in binary units.
+func FormatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// Torrent flags set by admins.
+const (
+	// Announces to the torrent are rejected.
+	TorrentFrozen = "frozen"
+	// Torrent is not listed in the index or the API.
+	TorrentHidden = "hidden"
+)
+
+var torrentFlags = []string{TorrentFrozen, TorrentHidden}
+
+func (t *Torrent) HasFlag(flag string) bool {
+	return slices.Contains(t.Flags, flag)
 }
 
 func (t *Torrent) MarshalJSON() ([]byte, error) {
 	type dto Torrent
+	flags := t.Flags
+	if flags == nil {
+		flags = []string{}
+	}
+	tags := t.Tags
+	if tags == nil {
+		tags = []string{}
+	}
 	return json.Marshal(struct {
 		ID        string    `json:"id"`
 		InfoHash  string    `json:"info_hash"`
@@ -27,6 +147,12 @@ func (t *Torrent) MarshalJSON() ([]byte, error) {
 		CreatedAt time.Time `json:"created_at"`
 		Seeders   int       `json:"seeders"`
 		Leechers  int       `json:"leechers"`
+		Flags     []string  `json:"flags"`
+		Name      string    `json:"name"`
+		Size      int64     `json:"size"`
+		Uploaded  bool      `json:"uploaded"`
+		Category  string    `json:"category"`
+		Tags      []string  `json:"tags"`
 		*dto
 	}{
 		ID:        t.ID.String(),
@@ -35,5 +161,11 @@ func (t *Torrent) MarshalJSON() ([]byte, error) {
 		CreatedAt: t.CreatedAt,
 		Seeders:   t.Seeders,
 		Leechers:  t.Leechers,
+		Flags:     flags,
+		Name:      t.Name,
+		Size:      t.Size,
+		Uploaded:  t.Uploaded,
+		Category:  t.Category,
+		Tags:      tags,
 	})
 }
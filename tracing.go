@@ -0,0 +1,70 @@
+package tracker
+
+import (
+	"context"
+	"encoding/hex"
+
+	"github.com/gofrs/uuid"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Name of the tracer of the tracker.
+const tracerName = "github.com/salimnassim/tracker"
+
+// Returns the tracer of the tracker. It does nothing until tracing is started.
+func tracer() trace.Tracer {
+	return otel.Tracer(tracerName)
+}
+
+// Starts exporting traces with OTLP over HTTP and accepting trace context from incoming headers.
+// The collector endpoint, sampler and service name are configured with the standard
+// OTEL_EXPORTER_OTLP_*, OTEL_TRACES_SAMPLER* and OTEL_SERVICE_NAME environment variables.
+// The returned function flushes pending spans and stops the exporter.
+func StartTracing(ctx context.Context) (func(context.Context) error, error) {
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES override the defaults
+	res, err := resource.New(ctx,
+		resource.WithTelemetrySDK(),
+		resource.WithAttributes(semconv.ServiceName("tracker")),
+		resource.WithFromEnv(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	return provider.Shutdown, nil
+}
+
+// Span attributes of announces and store calls.
+func infoHashAttr(infoHash []byte) attribute.KeyValue {
+	return attribute.String("tracker.info_hash", hex.EncodeToString(infoHash))
+}
+
+func eventAttr(event string) attribute.KeyValue {
+	return attribute.String("tracker.event", event)
+}
+
+func torrentIDAttr(torrentID uuid.UUID) attribute.KeyValue {
+	return attribute.String("tracker.torrent_id", torrentID.String())
+}
@@ -0,0 +1,47 @@
+package tracker
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Operator of the web UI.
+type User struct {
+	ID           uuid.UUID `db:"id"`
+	Username     string    `db:"username"`
+	PasswordHash string    `db:"password_hash"`
+	Scope        string    `db:"scope"`
+	CreatedAt    time.Time `db:"created_at"`
+	// Issuer and subject of users that sign in with OIDC.
+	Subject *string `db:"subject"`
+	// Secret in the announce URL of the user in private mode.
+	Passkey string `db:"passkey"`
+}
+
+// Returned when a username belongs to another user.
+var ErrUsernameTaken = errors.New("username is taken")
+
+// Compared against when a user does not exist, so unknown usernames
+// take as long to reject as wrong passwords.
+var dummyPasswordHash = []byte("$2a$10$LggQZKN/nuPAZXy6pL9bTOxjQVwxDZXDuuXQfk57iOfZwwIxKzY3G")
+
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// Reports whether password matches the hash of u.
+// A nil u and users without a password never match.
+func (u *User) CheckPassword(password string) bool {
+	if u == nil || u.PasswordHash == "" {
+		bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(password))
+		return false
+	}
+	return bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(password)) == nil
+}